@@ -0,0 +1,38 @@
+package opentracing_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/caddyserver/caddy/v2/modules/standard"
+	"github.com/n0trace/caddy-opentracing/tracingtest"
+)
+
+// TestAdapt adapts the Caddyfiles of testdata, and the example Caddyfile,
+// and compares the JSON to the golden files next to them. Run the tests
+// with UPDATE_GOLDEN=1 to rewrite the golden files.
+func TestAdapt(t *testing.T) {
+	caddyfiles, err := filepath.Glob(filepath.Join("testdata", "*.caddyfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldens := map[string]string{
+		"Caddyfile.example": filepath.Join("testdata", "Caddyfile.example.json"),
+	}
+	for _, caddyfile := range caddyfiles {
+		goldens[caddyfile] = strings.TrimSuffix(caddyfile, ".caddyfile") + ".json"
+	}
+
+	for caddyfile, golden := range goldens {
+		caddyfile, golden := caddyfile, golden
+		t.Run(filepath.Base(caddyfile), func(t *testing.T) {
+			body, err := ioutil.ReadFile(caddyfile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracingtest.AssertAdaptGolden(t, string(body), golden)
+		})
+	}
+}
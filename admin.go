@@ -0,0 +1,172 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+func init() {
+	caddy.RegisterModule(adminSampling{})
+}
+
+// adminSampling is a module that provides the /opentracing/sampling
+// endpoint for the Caddy admin API. It lists the samplers of the
+// provisioned tracers, and allows to override them temporarily, e.g.
+// to sample every request of a service during an incident:
+//
+//	curl -X POST localhost:2019/opentracing/sampling \
+//		-d '{"service_name": "caddy", "type": "const", "param": 1, "ttl": "10m"}'
+//
+// A DELETE request, optionally with a service_name query parameter,
+// reverts the overrides before they expire. The tracers created for the
+// sites are listed and overridden under their own service names, and
+// follow the overrides of their handler unless overridden themselves.
+type adminSampling struct{}
+
+// samplerStatus describes the sampler of a tracer.
+type samplerStatus struct {
+	ServiceName string           `json:"service_name"`
+	Sampler     string           `json:"sampler"`
+	Override    *samplerOverride `json:"override,omitempty"`
+}
+
+// overrideRequest is the body of a POST request.
+type overrideRequest struct {
+	// ServiceName selects the tracers to override. All of them
+	// are overridden if it is empty.
+	ServiceName string         `json:"service_name"`
+	Type        string         `json:"type"`
+	Param       float64        `json:"param"`
+	TTL         caddy.Duration `json:"ttl"`
+}
+
+// CaddyModule returns the Caddy module information.
+func (adminSampling) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "admin.api.opentracing",
+		New: func() caddy.Module { return new(adminSampling) },
+	}
+}
+
+// Routes returns a route for the /opentracing/sampling endpoint.
+func (as adminSampling) Routes() []caddy.AdminRoute {
+	return []caddy.AdminRoute{
+		{
+			Pattern: "/opentracing/sampling",
+			Handler: caddy.AdminHandlerFunc(as.handleSampling),
+		},
+	}
+}
+
+func (as adminSampling) handleSampling(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		return as.listSamplers(w)
+	case http.MethodPost:
+		return as.setOverride(w, r)
+	case http.MethodDelete:
+		return as.clearOverride(w, r)
+	default:
+		return caddy.APIError{
+			HTTPStatus: http.StatusMethodNotAllowed,
+			Err:        fmt.Errorf("method not allowed"),
+		}
+	}
+}
+
+// listSamplers reports the sampler of every provisioned tracer.
+func (adminSampling) listSamplers(w http.ResponseWriter) error {
+	results := []samplerStatus{}
+	rangeSamplers(func(serviceName string, sampler *overridableSampler) {
+		results = append(results, samplerStatus{
+			ServiceName: serviceName,
+			Sampler:     sampler.String(),
+			Override:    sampler.activeOverride(),
+		})
+	})
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ServiceName < results[j].ServiceName
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return caddy.APIError{
+			HTTPStatus: http.StatusInternalServerError,
+			Err:        err,
+		}
+	}
+	return nil
+}
+
+// setOverride overrides the samplers of the requested tracers.
+func (adminSampling) setOverride(w http.ResponseWriter, r *http.Request) error {
+	var req overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return caddy.APIError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        fmt.Errorf("decoding request: %v", err),
+		}
+	}
+
+	var matched int
+	var overrideErr error
+	rangeSamplers(func(serviceName string, sampler *overridableSampler) {
+		if req.ServiceName != "" && serviceName != req.ServiceName {
+			return
+		}
+		matched++
+		if err := sampler.setOverride(req.Type, req.Param, time.Duration(req.TTL)); err != nil {
+			overrideErr = err
+		}
+	})
+	if overrideErr != nil {
+		return caddy.APIError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        overrideErr,
+		}
+	}
+	if matched == 0 {
+		return caddy.APIError{
+			HTTPStatus: http.StatusNotFound,
+			Err:        fmt.Errorf("no tracer for service %q", req.ServiceName),
+		}
+	}
+	return nil
+}
+
+// clearOverride reverts the samplers of the requested tracers.
+func (adminSampling) clearOverride(w http.ResponseWriter, r *http.Request) error {
+	serviceName := r.URL.Query().Get("service_name")
+	rangeSamplers(func(name string, sampler *overridableSampler) {
+		if serviceName == "" || name == serviceName {
+			sampler.clearOverride()
+		}
+	})
+	return nil
+}
+
+// rangeSamplers calls f with the sampler of every provisioned tracer,
+// including the tracers created for the sites.
+func rangeSamplers(f func(serviceName string, sampler *overridableSampler)) {
+	rangeHandlers(func(tracing *Opentracing) {
+		if tracing.sampler == nil {
+			return
+		}
+		f(tracing.serviceName, tracing.sampler)
+		if tracing.sites != nil {
+			tracing.sites.each(func(serviceName string, t siteTracer) {
+				if t.sampler != nil {
+					f(serviceName, t.sampler)
+				}
+			})
+		}
+	})
+}
+
+// Interface guard
+var _ caddy.AdminRouter = (*adminSampling)(nil)
@@ -0,0 +1,84 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/uber/jaeger-client-go"
+)
+
+func adminRequest(method, target, body string) (*httptest.ResponseRecorder, error) {
+	w := httptest.NewRecorder()
+	err := adminSampling{}.handleSampling(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w, err
+}
+
+func TestAdminSamplingErrors(t *testing.T) {
+	defer SetTestReporter(jaeger.NewInMemoryReporter())()
+	provision(t, &Opentracing{
+		Config:       Config{ServiceName: "caddy", Sampler: constSampler(false)},
+		EnvOverrides: envIgnore,
+	})
+
+	for _, tc := range []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"bad body", http.MethodPost, `{`, http.StatusBadRequest},
+		{"bad type", http.MethodPost, `{"type": "remote", "param": 1, "ttl": "1m"}`, http.StatusBadRequest},
+		{"no ttl", http.MethodPost, `{"type": "const", "param": 1}`, http.StatusBadRequest},
+		{"unknown service", http.MethodPost, `{"service_name": "other", "type": "const", "param": 1, "ttl": "1m"}`, http.StatusNotFound},
+		{"bad method", http.MethodPut, ``, http.StatusMethodNotAllowed},
+	} {
+		_, err := adminRequest(tc.method, "/opentracing/sampling", tc.body)
+		var apiErr caddy.APIError
+		if !errors.As(err, &apiErr) || apiErr.HTTPStatus != tc.wantStatus {
+			t.Errorf("%s: got %v, want status %d", tc.name, err, tc.wantStatus)
+		}
+	}
+}
+
+func TestAdminSamplingOverride(t *testing.T) {
+	defer SetTestReporter(jaeger.NewInMemoryReporter())()
+	tracing := provision(t, &Opentracing{
+		Config:       Config{ServiceName: "caddy", Sampler: constSampler(false)},
+		EnvOverrides: envIgnore,
+	})
+	list := func() []samplerStatus {
+		t.Helper()
+		w, err := adminRequest(http.MethodGet, "/opentracing/sampling", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var statuses []samplerStatus
+		if err := json.NewDecoder(w.Body).Decode(&statuses); err != nil {
+			t.Fatal(err)
+		}
+		return statuses
+	}
+
+	if _, err := adminRequest(http.MethodPost, "/opentracing/sampling", `{"service_name": "caddy", "type": "const", "param": 1, "ttl": "10m"}`); err != nil {
+		t.Fatal(err)
+	}
+	statuses := list()
+	if len(statuses) != 1 || statuses[0].ServiceName != "caddy" || statuses[0].Override == nil || statuses[0].Override.Type != "const" {
+		t.Errorf("statuses %+v, want caddy overridden", statuses)
+	}
+	if tracing.sampler.activeOverride() == nil {
+		t.Error("sampler not overridden")
+	}
+
+	if _, err := adminRequest(http.MethodDelete, "/opentracing/sampling?service_name=caddy", ""); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := list(); len(statuses) != 1 || statuses[0].Override != nil {
+		t.Errorf("statuses %+v, want no override", statuses)
+	}
+}
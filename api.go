@@ -0,0 +1,99 @@
+package opentracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// serverSpanCtxKey is the context key of the server span started by the
+// opentracing handler, which stays the same when the handlers that follow
+// put child spans on the context.
+type serverSpanCtxKey struct{}
+
+// activeSpanCtxKey is the context key opentracing.ContextWithSpan uses,
+// captured by asking opentracing.SpanFromContext to look it up.
+var activeSpanCtxKey = func() (key interface{}) {
+	opentracing.SpanFromContext(keyProbe{Context: context.Background(), key: &key})
+	return
+}()
+
+type keyProbe struct {
+	context.Context
+	key *interface{}
+}
+
+func (p keyProbe) Value(key interface{}) interface{} {
+	*p.key = key
+	return nil
+}
+
+// serverSpanContext carries the server span both as the active span of
+// opentracing and under serverSpanCtxKey, with a single context layer.
+type serverSpanContext struct {
+	context.Context
+	sp opentracing.Span
+}
+
+func (c *serverSpanContext) Value(key interface{}) interface{} {
+	if key == activeSpanCtxKey || key == (serverSpanCtxKey{}) {
+		return c.sp
+	}
+	return c.Context.Value(key)
+}
+
+// SpanFromRequest returns the server span started for r by the opentracing
+// handler, or nil when the handler did not trace r. Unlike
+// opentracing.SpanFromContext, it ignores the child spans started after it.
+func SpanFromRequest(r *http.Request) opentracing.Span {
+	sp, _ := r.Context().Value(serverSpanCtxKey{}).(opentracing.Span)
+	return sp
+}
+
+// TracerFromRequest returns the tracer of the opentracing handler which
+// traced r, or a no-op tracer when no handler traced r.
+func TracerFromRequest(r *http.Request) opentracing.Tracer {
+	if sp := SpanFromRequest(r); sp != nil {
+		return sp.Tracer()
+	}
+	return opentracing.NoopTracer{}
+}
+
+// StartChildSpan starts a span named operationName, child of the span on the
+// context of r, which is the server span unless a child span replaced it,
+// with the tracer of the handler which traced r. It returns the span and a
+// shallow copy of r carrying it, to pass on to the next handlers. The caller
+// must finish the span.
+func StartChildSpan(r *http.Request, operationName string, opts ...opentracing.StartSpanOption) (opentracing.Span, *http.Request) {
+	sp, ctx := opentracing.StartSpanFromContextWithTracer(r.Context(), TracerFromRequest(r), operationName, opts...)
+	return sp, r.WithContext(ctx)
+}
+
+// TracerFor returns the tracer of the opentracing handler of the service
+// name provisioned in the same configuration as ctx, e.g. for a module
+// which needs a tracer outside of the handler chain. With an empty name,
+// any handler of the configuration matches. When several handlers match,
+// the first one provisioned wins. It returns a no-op tracer when no handler
+// matches, which includes the handlers not provisioned yet, so modules
+// should look the tracer up lazily rather than in Provision.
+func TracerFor(ctx caddy.Context, serviceName string) opentracing.Tracer {
+	var tr opentracing.Tracer
+	rangeHandlers(func(tracing *Opentracing) {
+		if tr != nil || tracing.cfgCtx != ctx.Context {
+			return
+		}
+		if serviceName == "" || tracing.serviceName == serviceName {
+			tr = tracing.tr
+		} else if tracing.sites != nil {
+			if site, ok := tracing.sites.lookup(serviceName); ok {
+				tr = site.tr
+			}
+		}
+	})
+	if tr == nil {
+		return opentracing.NoopTracer{}
+	}
+	return tr
+}
@@ -0,0 +1,80 @@
+package opentracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+func TestSpanFromRequest(t *testing.T) {
+	tracing := &Opentracing{}
+	tr := provisionMock(t, tracing)
+
+	if sp := SpanFromRequest(httptest.NewRequest(http.MethodGet, "/", nil)); sp != nil {
+		t.Errorf("span of an untraced request = %v, want nil", sp)
+	}
+
+	var server, fromChild opentracing.Span
+	var childTracer opentracing.Tracer
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) error {
+		server = SpanFromRequest(r)
+		child, r := StartChildSpan(r, "child")
+		defer child.Finish()
+		fromChild = SpanFromRequest(r)
+		childTracer = TracerFromRequest(r)
+		return nil
+	})
+
+	if server == nil {
+		t.Fatal("no server span")
+	}
+	if fromChild != server {
+		t.Error("SpanFromRequest returned the child span")
+	}
+	if childTracer != tr {
+		t.Error("TracerFromRequest did not return the tracer of the handler")
+	}
+	spans := tr.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans finished, want 2", len(spans))
+	}
+	if spans[0].ParentID != spans[1].SpanContext.SpanID {
+		t.Error("child span is not a child of the server span")
+	}
+}
+
+func TestTracerFromRequestUntraced(t *testing.T) {
+	if _, ok := TracerFromRequest(httptest.NewRequest(http.MethodGet, "/", nil)).(opentracing.NoopTracer); !ok {
+		t.Error("tracer of an untraced request is not a no-op tracer")
+	}
+}
+
+func TestTracerFor(t *testing.T) {
+	ctx := newContext(t)
+	var tracings []*Opentracing
+	for _, name := range []string{"first", "second", "third"} {
+		tracing := provisionIn(t, ctx, &Opentracing{
+			Config:       Config{ServiceName: name, Sampler: constSampler(true)},
+			EnvOverrides: envIgnore,
+		})
+		tracings = append(tracings, tracing)
+	}
+
+	if got := TracerFor(ctx, "second"); got != tracings[1].tr {
+		t.Error("TracerFor(second) did not return the tracer of second")
+	}
+	// the first handler provisioned wins
+	for i := 0; i < 10; i++ {
+		if got := TracerFor(ctx, ""); got != tracings[0].tr {
+			t.Fatal("TracerFor(\"\") did not return the tracer of first")
+		}
+	}
+	if _, ok := TracerFor(ctx, "unknown").(opentracing.NoopTracer); !ok {
+		t.Error("TracerFor(unknown) is not a no-op tracer")
+	}
+	if _, ok := TracerFor(newContext(t), "").(opentracing.NoopTracer); !ok {
+		t.Error("TracerFor of another configuration is not a no-op tracer")
+	}
+}
@@ -0,0 +1,148 @@
+package opentracing
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// BaggageConfig controls the baggage accepted from the incoming trace
+// context, without the remote restrictions manager of jaeger-agent, and
+// exposes selected baggage items to the handlers that follow.
+type BaggageConfig struct {
+	// Allow lists the accepted baggage keys. Every key is accepted when empty.
+	Allow []string `json:"allow"`
+
+	// MaxKeyLength drops the baggage items with longer keys.
+	MaxKeyLength int `json:"max_key_length"`
+
+	// MaxValueLength drops the baggage items with longer values.
+	MaxValueLength int `json:"max_value_length"`
+
+	// MaxTotalSize bounds the total length of the keys and values of the
+	// accepted baggage. Items are accepted in the order of their keys.
+	MaxTotalSize int `json:"max_total_size"`
+
+	// Headers maps baggage keys to request headers, which are set for the
+	// handlers that follow, e.g. to pass baggage on to upstreams.
+	Headers map[string]string `json:"headers"`
+
+	// Tags maps baggage keys to tags of the request span.
+	Tags map[string]string `json:"tags"`
+}
+
+// accepts reports whether the baggage item is allowed on its own.
+func (bc *BaggageConfig) accepts(key, value string) bool {
+	if bc.MaxKeyLength > 0 && len(key) > bc.MaxKeyLength {
+		return false
+	}
+	if bc.MaxValueLength > 0 && len(value) > bc.MaxValueLength {
+		return false
+	}
+	if len(bc.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range bc.Allow {
+		if strings.EqualFold(allowed, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter removes the baggage items of ctx which are not accepted, as well
+// as their raw headers from header, so that they are not passed on to the
+// upstreams either.
+func (bc *BaggageConfig) filter(ctx opentracing.SpanContext, header http.Header, names *jaeger.HeadersConfig) opentracing.SpanContext {
+	jsc, ok := ctx.(jaeger.SpanContext)
+	if !ok {
+		return ctx
+	}
+
+	items := make(map[string]string)
+	jsc.ForeachBaggageItem(func(k, v string) bool {
+		items[k] = v
+		return true
+	})
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var total int
+	rejected := make(map[string]bool)
+	for _, k := range keys {
+		v := items[k]
+		if bc.accepts(k, v) && (bc.MaxTotalSize <= 0 || total+len(k)+len(v) <= bc.MaxTotalSize) {
+			total += len(k) + len(v)
+			continue
+		}
+		jsc = jsc.WithBaggageItem(k, "")
+		rejected[k] = true
+	}
+	if len(rejected) > 0 {
+		stripBaggageHeaders(header, names, rejected)
+	}
+	return jsc
+}
+
+// stripBaggageHeaders removes the rejected baggage items from the baggage
+// headers, the way jaeger reads them: one header per item, and a header
+// holding a comma separated list of items.
+func stripBaggageHeaders(header http.Header, names *jaeger.HeadersConfig, rejected map[string]bool) {
+	for name := range header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, names.TraceBaggageHeaderPrefix) && rejected[lower[len(names.TraceBaggageHeaderPrefix):]] {
+			delete(header, name)
+		}
+	}
+
+	values := header.Values(names.JaegerBaggageHeader)
+	if len(values) == 0 {
+		return
+	}
+	var kept []string
+	for _, value := range values {
+		value, err := url.QueryUnescape(value)
+		if err != nil {
+			// jaeger ignores the value too
+			continue
+		}
+		for _, item := range strings.Split(value, ",") {
+			kv := strings.Split(strings.TrimSpace(item), "=")
+			if len(kv) == 2 && !rejected[strings.TrimSpace(kv[0])] {
+				kept = append(kept, strings.TrimSpace(item))
+			}
+		}
+	}
+	if len(kept) == 0 {
+		header.Del(names.JaegerBaggageHeader)
+		return
+	}
+	header.Set(names.JaegerBaggageHeader, strings.Join(kept, ", "))
+}
+
+// exposeHeaders copies the mapped baggage items of sp to the headers of r,
+// whether or not sp is sampled, since the upstreams need them either way.
+func (bc *BaggageConfig) exposeHeaders(sp opentracing.Span, r *http.Request) {
+	for key, header := range bc.Headers {
+		if v := sp.BaggageItem(key); v != "" {
+			r.Header.Set(header, v)
+		}
+	}
+}
+
+// exposeTags copies the mapped baggage items of sp to its tags, redacting
+// them with redact.
+func (bc *BaggageConfig) exposeTags(sp opentracing.Span, redact *RedactConfig) {
+	for key, tag := range bc.Tags {
+		if v := sp.BaggageItem(key); v != "" {
+			sp.SetTag(tag, redact.value(v))
+		}
+	}
+}
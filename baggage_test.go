@@ -0,0 +1,121 @@
+package opentracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestBaggageFilter(t *testing.T) {
+	tracing := provision(t, &Opentracing{
+		Config:       Config{Sampler: constSampler(true)},
+		EnvOverrides: envIgnore,
+		Baggage: &BaggageConfig{
+			Allow:   []string{"tenant", "user"},
+			Headers: map[string]string{"tenant": "X-Tenant"},
+		},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Uber-Trace-Id", "1f:2f:0:1")
+	r.Header.Set("Uberctx-Tenant", "acme")
+	r.Header.Set("Uberctx-Secret", "hunter2")
+	r.Header.Set("Jaeger-Baggage", "user=alice, password=hunter2")
+
+	var header http.Header
+	var baggage map[string]string
+	serve(t, tracing, r, func(w http.ResponseWriter, r *http.Request) error {
+		header = r.Header.Clone()
+		baggage = make(map[string]string)
+		opentracing.SpanFromContext(r.Context()).Context().ForeachBaggageItem(func(k, v string) bool {
+			baggage[k] = v
+			return true
+		})
+		return nil
+	})
+
+	want := map[string]string{"tenant": "acme", "user": "alice"}
+	if len(baggage) != len(want) || baggage["tenant"] != want["tenant"] || baggage["user"] != want["user"] {
+		t.Errorf("baggage = %v, want %v", baggage, want)
+	}
+	for name, want := range map[string]string{
+		"Uberctx-Tenant": "acme",
+		"Uberctx-Secret": "",
+		"Jaeger-Baggage": "user=alice",
+		"X-Tenant":       "acme",
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBaggageExpose(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		reporter := jaeger.NewInMemoryReporter()
+		restore := SetTestReporter(reporter)
+		tracing := provision(t, &Opentracing{
+			Config:       Config{Sampler: constSampler(sampled)},
+			EnvOverrides: envIgnore,
+			Baggage: &BaggageConfig{
+				Headers: map[string]string{"tenant": "X-Tenant"},
+				Tags:    map[string]string{"tenant": "baggage.tenant"},
+			},
+		})
+		restore()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Uberctx-Tenant", "acme")
+		var tenant string
+		serve(t, tracing, r, func(w http.ResponseWriter, r *http.Request) error {
+			tenant = r.Header.Get("X-Tenant")
+			return nil
+		})
+
+		// upstreams get the baggage whether or not the trace is sampled
+		if tenant != "acme" {
+			t.Errorf("sampled %v: X-Tenant = %q, want acme", sampled, tenant)
+		}
+		spans := reporter.GetSpans()
+		if sampled != (len(spans) == 1) {
+			t.Fatalf("sampled %v: %d spans reported", sampled, len(spans))
+		}
+		if sampled {
+			if got := spans[0].(*jaeger.Span).Tags()["baggage.tenant"]; got != "acme" {
+				t.Errorf("baggage.tenant tag = %v, want acme", got)
+			}
+		}
+	}
+}
+
+func TestBaggageFilterLimits(t *testing.T) {
+	bc := &BaggageConfig{MaxKeyLength: 4, MaxValueLength: 4, MaxTotalSize: 6}
+	ctx := jaeger.NewSpanContext(jaeger.TraceID{Low: 1}, 1, 0, true, map[string]string{
+		"a":     "1",
+		"b":     "22",
+		"c":     "333",
+		"long!": "1",
+		"d":     "55555",
+	})
+	header := http.Header{
+		"Uberctx-A":     {"1"},
+		"Uberctx-C":     {"333"},
+		"Uberctx-Long!": {"1"},
+	}
+	names := jaeger.HeadersConfig{TraceBaggageHeaderPrefix: "uberctx-", JaegerBaggageHeader: "jaeger-baggage"}
+
+	got := make(map[string]string)
+	bc.filter(ctx, header, &names).ForeachBaggageItem(func(k, v string) bool {
+		got[k] = v
+		return true
+	})
+	// items are accepted in the order of their keys until the total size
+	if len(got) != 2 || got["a"] != "1" || got["b"] != "22" {
+		t.Errorf("baggage = %v, want a and b", got)
+	}
+	if len(header) != 1 || header.Get("Uberctx-A") != "1" {
+		t.Errorf("headers = %v, want Uberctx-A", header)
+	}
+}
@@ -0,0 +1,133 @@
+package opentracing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"unicode/utf8"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+const (
+	defaultBodyCaptureBytes = 1024
+	maxBodyCaptureBytes     = 64 * 1024
+)
+
+var defaultBodyContentTypes = []string{"application/json", "text/plain"}
+
+// BodyCaptureConfig records the beginning of the request and response
+// bodies of sampled requests as span logs. Bodies which look binary are
+// skipped.
+type BodyCaptureConfig struct {
+	// MatchersRaw restricts the capture to the requests matching one of
+	// the matcher sets.
+	MatchersRaw caddyhttp.RawMatcherSets `json:"match" caddy:"namespace=http.matchers"`
+
+	// MaxBytes is the number of bytes captured from each body.
+	// Default 1024, at most 65536.
+	MaxBytes int `json:"max_bytes"`
+
+	// ContentTypes lists the media types of the bodies to capture, which
+	// may end with a wildcard, e.g. text/*. Default application/json and
+	// text/plain.
+	ContentTypes []string `json:"content_types"`
+
+	matchers caddyhttp.MatcherSets
+}
+
+// provision loads the matchers and applies the defaults.
+func (bc *BodyCaptureConfig) provision(ctx caddy.Context) error {
+	if bc.MaxBytes <= 0 {
+		bc.MaxBytes = defaultBodyCaptureBytes
+	}
+	if bc.MaxBytes > maxBodyCaptureBytes {
+		return fmt.Errorf("max_bytes must be at most %d", maxBodyCaptureBytes)
+	}
+	if len(bc.ContentTypes) == 0 {
+		bc.ContentTypes = defaultBodyContentTypes
+	}
+	mods, err := ctx.LoadModule(bc, "MatchersRaw")
+	if err != nil {
+		return fmt.Errorf("loading matchers: %v", err)
+	}
+	return bc.matchers.FromInterface(mods)
+}
+
+func (bc *BodyCaptureConfig) match(r *http.Request) bool {
+	return len(bc.matchers) == 0 || bc.matchers.AnyMatch(r)
+}
+
+// captures reports whether a body with the given Content-Type is captured.
+func (bc *BodyCaptureConfig) captures(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range bc.ContentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// log records the captured body on sp under the given event, unless its
+// Content-Type is not captured or it looks binary.
+func (bc *BodyCaptureConfig) log(sp opentracing.Span, event, contentType string, body *bodyBuffer, redact *RedactConfig) {
+	if body == nil || len(body.buf) == 0 || !bc.captures(contentType) || body.binary() {
+		return
+	}
+	sp.LogFields(
+		log.String("event", event),
+		log.String("body", redact.text(string(body.buf))),
+		log.Bool("truncated", body.truncated),
+	)
+}
+
+// bodyBuffer keeps the first max bytes written to it.
+type bodyBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *bodyBuffer) record(p []byte) {
+	room := b.max - len(b.buf)
+	if len(p) > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.buf = append(b.buf, p...)
+}
+
+// binary reports whether the buffer does not look like text. A truncated
+// buffer may end in the middle of a character, which is allowed.
+func (b *bodyBuffer) binary() bool {
+	if bytes.IndexByte(b.buf, 0) >= 0 {
+		return true
+	}
+	text := b.buf
+	if b.truncated && len(text) > utf8.UTFMax {
+		text = text[:len(text)-utf8.UTFMax]
+	}
+	return !utf8.Valid(text)
+}
+
+// bodyRecorder records the beginning of the request body as it is read.
+type bodyRecorder struct {
+	io.ReadCloser
+	*bodyBuffer
+}
+
+func (br bodyRecorder) Read(p []byte) (int, error) {
+	n, err := br.ReadCloser.Read(p)
+	br.record(p[:n])
+	return n, err
+}
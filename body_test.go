@@ -0,0 +1,137 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestBodyBufferRecord(t *testing.T) {
+	b := &bodyBuffer{max: 8}
+	b.record([]byte("abc"))
+	b.record([]byte("defg"))
+	if string(b.buf) != "abcdefg" || b.truncated {
+		t.Errorf("buffer %q truncated %v, want abcdefg not truncated", b.buf, b.truncated)
+	}
+	b.record([]byte("hij"))
+	b.record([]byte("k"))
+	if string(b.buf) != "abcdefgh" || !b.truncated {
+		t.Errorf("buffer %q truncated %v, want abcdefgh truncated", b.buf, b.truncated)
+	}
+}
+
+func TestBodyBufferBinary(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		buf       string
+		truncated bool
+		want      bool
+	}{
+		{name: "text", buf: `{"name": "café"}`},
+		{name: "NUL byte", buf: "abc\x00def", want: true},
+		{name: "invalid UTF-8", buf: "abc\xffdef", want: true},
+		{name: "incomplete tail", buf: "abcdef\xc3", want: true},
+		{name: "truncated tail", buf: "abcdef\xc3", truncated: true},
+		{name: "truncated invalid", buf: "ab\xffcdefgh", truncated: true, want: true},
+	} {
+		b := &bodyBuffer{buf: []byte(tc.buf), truncated: tc.truncated}
+		if got := b.binary(); got != tc.want {
+			t.Errorf("%s: binary = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBodyCaptures(t *testing.T) {
+	bc := &BodyCaptureConfig{ContentTypes: []string{"application/json", "text/*"}}
+	for contentType, want := range map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"text/html":                       true,
+		"TEXT/Plain":                      true,
+		"application/xml":                 false,
+		"image/png":                       false,
+		"":                                false,
+		"not a media type;":               false,
+	} {
+		if got := bc.captures(contentType); got != want {
+			t.Errorf("captures(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestBodyCaptureServeHTTP(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sampled bool
+		path    string
+		want    bool
+	}{
+		{name: "sampled", sampled: true, path: "/api/items", want: true},
+		{name: "unsampled", sampled: false, path: "/api/items"},
+		{name: "not matching", sampled: true, path: "/static/items"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := jaeger.NewInMemoryReporter()
+			restore := SetTestReporter(reporter)
+			tracing := provision(t, &Opentracing{
+				Config:       Config{Sampler: constSampler(tc.sampled)},
+				EnvOverrides: envIgnore,
+				BodyCapture: &BodyCaptureConfig{
+					MatchersRaw: caddyhttp.RawMatcherSets{{"path": json.RawMessage(`["/api/*"]`)}},
+					MaxBytes:    16,
+				},
+			})
+			restore()
+
+			r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{"name": "a long enough name"}`))
+			r.Header.Set("Content-Type", "application/json")
+			var recorded bool
+			serve(t, tracing, r, func(w http.ResponseWriter, r *http.Request) error {
+				_, recorded = r.Body.(bodyRecorder)
+				if _, err := io.ReadAll(r.Body); err != nil {
+					return err
+				}
+				w.Header().Set("Content-Type", "text/plain")
+				_, err := w.Write([]byte("created"))
+				return err
+			})
+			if recorded != tc.want {
+				t.Errorf("request body recorded %v, want %v", recorded, tc.want)
+			}
+
+			spans := reporter.GetSpans()
+			if tc.sampled != (len(spans) == 1) {
+				t.Fatalf("%d spans reported", len(spans))
+			}
+			if !tc.sampled {
+				return
+			}
+			logs := make(map[string]map[string]interface{})
+			for _, record := range spans[0].(*jaeger.Span).Logs() {
+				fields := make(map[string]interface{})
+				for _, f := range record.Fields {
+					fields[f.Key()] = f.Value()
+				}
+				logs[fields["event"].(string)] = fields
+			}
+			if !tc.want {
+				if len(logs) != 0 {
+					t.Errorf("logs %v, want none", logs)
+				}
+				return
+			}
+			if req := logs["http.request.body"]; req["body"] != `{"name": "a long` || req["truncated"] != true {
+				t.Errorf("request body log %v", req)
+			}
+			if resp := logs["http.response.body"]; resp["body"] != "created" || resp["truncated"] != false {
+				t.Errorf("response body log %v", resp)
+			}
+		})
+	}
+}
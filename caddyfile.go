@@ -1,11 +1,11 @@
 package opentracing
 
 import (
-	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
@@ -33,12 +33,48 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 					return d.ArgErr()
 				}
 				cfg.ServiceName = d.Val()
+			case "env_overrides":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				tracing.EnvOverrides = d.Val()
 			case "disabled":
 				cfg.Disabled = true
 			case "rpc_metrics":
 				cfg.RPCMetrics = true
 			case "traceid_128bit":
 				cfg.Gen128Bit = true
+			case "component_name":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				tracing.ComponentName = d.Val()
+			case "operation_name":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				tracing.OperationName = d.Val()
+			case "skip":
+				var matcherSet caddy.ModuleMap
+				if matcherSet, err = parseMatcherSet(d); err != nil {
+					return
+				}
+				tracing.SkipMatchersRaw = append(tracing.SkipMatchersRaw, matcherSet)
+			case "upstream_spans":
+				tracing.UpstreamSpans = true
+			case "response_header":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				tracing.ResponseHeader = d.Val()
+			case "log_fields":
+				tracing.LogFields = true
+				if d.NextArg() {
+					if d.Val() != "sampled_only" {
+						return d.Errf("unrecognized log_fields option '%s'", d.Val())
+					}
+					tracing.LogFieldsSampledOnly = true
+				}
 			case "sampler":
 				cfg.Sampler = new(SamplerConfig)
 				for nesting := d.Nesting(); d.NextBlock(nesting); {
@@ -64,9 +100,11 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 						if !d.NextArg() {
 							return d.ArgErr()
 						}
-						if cfg.Sampler.SamplingRefreshInterval, err = time.ParseDuration(d.Val()); err != nil {
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
 							return
 						}
+						cfg.Sampler.SamplingRefreshInterval = caddy.Duration(dur)
 					case "max_operations":
 						if !d.NextArg() {
 							return d.ArgErr()
@@ -76,6 +114,13 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 						}
 					case "operation_name_late_binding":
 						cfg.Sampler.OperationNameLateBinding = true
+					case "strategies_file":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						cfg.Sampler.StrategiesFile = d.Val()
+					default:
+						return d.Errf("unrecognized sampler option '%s'", d.Val())
 					}
 				}
 			case "reporter":
@@ -106,16 +151,20 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 						if !d.NextArg() {
 							return d.ArgErr()
 						}
-						if cfg.Reporter.BufferFlushInterval, err = time.ParseDuration(d.Val()); err != nil {
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
 							return
 						}
+						cfg.Reporter.BufferFlushInterval = caddy.Duration(dur)
 					case "attempt_reconnect_interval":
 						if !d.NextArg() {
 							return d.ArgErr()
 						}
-						if cfg.Reporter.AttemptReconnectInterval, err = time.ParseDuration(d.Val()); err != nil {
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
 							return
 						}
+						cfg.Reporter.AttemptReconnectInterval = caddy.Duration(dur)
 					case "queue_size":
 						if !d.NextArg() {
 							return d.ArgErr()
@@ -128,8 +177,263 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 					case "disable_attempt_reconnecting":
 						cfg.Reporter.DisableAttemptReconnecting = true
 					case "http_headers":
+						if cfg.Reporter.HTTPHeaders == nil {
+							cfg.Reporter.HTTPHeaders = make(map[string]string)
+						}
+						for nesting := d.Nesting(); d.NextBlock(nesting); {
+							name := d.Val()
+							if !d.NextArg() {
+								return d.ArgErr()
+							}
+							cfg.Reporter.HTTPHeaders[name] = d.Val()
+							if d.NextArg() {
+								return d.ArgErr()
+							}
+						}
+					default:
+						return d.Errf("unrecognized reporter option '%s'", d.Val())
 					}
 				}
+			case "tail_sampling":
+				tracing.TailSampling = new(TailSamplingConfig)
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "min_status":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.TailSampling.MinStatus, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "min_duration":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
+							return
+						}
+						tracing.TailSampling.MinDuration = caddy.Duration(dur)
+					case "paths":
+						tracing.TailSampling.Paths = append(tracing.TailSampling.Paths, d.RemainingArgs()...)
+					case "probability":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.TailSampling.Probability, err = strconv.ParseFloat(d.Val(), 64); err != nil {
+							return
+						}
+					case "max_traces":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.TailSampling.MaxTraces, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "max_spans_per_trace":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.TailSampling.MaxSpansPerTrace, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "decision_wait":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
+							return
+						}
+						tracing.TailSampling.DecisionWait = caddy.Duration(dur)
+					default:
+						return d.Errf("unrecognized tail_sampling option '%s'", d.Val())
+					}
+				}
+			case "sampling_rules":
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					rule := &SamplingRule{Type: d.Val()}
+					if !d.NextArg() {
+						return d.ArgErr()
+					}
+					if rule.Param, err = strconv.ParseFloat(d.Val(), 64); err != nil {
+						return
+					}
+					var matcherSet caddy.ModuleMap
+					if matcherSet, err = parseMatcherSet(d); err != nil {
+						return
+					}
+					if len(matcherSet) > 0 {
+						rule.MatchersRaw = caddyhttp.RawMatcherSets{matcherSet}
+					}
+					tracing.SamplingRules = append(tracing.SamplingRules, rule)
+				}
+			case "force_sample":
+				tracing.ForceSample = new(ForceSampleConfig)
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "header":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						tracing.ForceSample.Header = d.Val()
+					case "cookie":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						tracing.ForceSample.Cookie = d.Val()
+					case "secret":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						tracing.ForceSample.Secret = d.Val()
+					case "max_age":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
+							return
+						}
+						tracing.ForceSample.MaxAge = caddy.Duration(dur)
+					default:
+						return d.Errf("unrecognized force_sample option '%s'", d.Val())
+					}
+				}
+			case "trust_context":
+				tracing.TrustContext = new(TrustConfig)
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "sources":
+						tracing.TrustContext.Sources = append(tracing.TrustContext.Sources, d.RemainingArgs()...)
+					case "match":
+						var matcherSet caddy.ModuleMap
+						if matcherSet, err = parseMatcherSet(d); err != nil {
+							return
+						}
+						tracing.TrustContext.MatchersRaw = append(tracing.TrustContext.MatchersRaw, matcherSet)
+					case "untrusted":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						tracing.TrustContext.Untrusted = d.Val()
+					case "strip_headers":
+						tracing.TrustContext.StripHeaders = true
+					default:
+						return d.Errf("unrecognized trust_context option '%s'", d.Val())
+					}
+				}
+			case "baggage":
+				tracing.Baggage = new(BaggageConfig)
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "allow":
+						tracing.Baggage.Allow = append(tracing.Baggage.Allow, d.RemainingArgs()...)
+					case "max_key_length":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.Baggage.MaxKeyLength, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "max_value_length":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.Baggage.MaxValueLength, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "max_total_size":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.Baggage.MaxTotalSize, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "header":
+						args := d.RemainingArgs()
+						if len(args) != 2 {
+							return d.ArgErr()
+						}
+						if tracing.Baggage.Headers == nil {
+							tracing.Baggage.Headers = make(map[string]string)
+						}
+						tracing.Baggage.Headers[args[0]] = args[1]
+					case "tag":
+						args := d.RemainingArgs()
+						if len(args) != 2 {
+							return d.ArgErr()
+						}
+						if tracing.Baggage.Tags == nil {
+							tracing.Baggage.Tags = make(map[string]string)
+						}
+						tracing.Baggage.Tags[args[0]] = args[1]
+					default:
+						return d.Errf("unrecognized baggage option '%s'", d.Val())
+					}
+				}
+			case "redact":
+				tracing.Redact = new(RedactConfig)
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "query_params":
+						tracing.Redact.QueryParams = append(tracing.Redact.QueryParams, d.RemainingArgs()...)
+					case "path_patterns":
+						tracing.Redact.PathPatterns = append(tracing.Redact.PathPatterns, d.RemainingArgs()...)
+					case "drop_query":
+						tracing.Redact.DropQuery = true
+					default:
+						return d.Errf("unrecognized redact option '%s'", d.Val())
+					}
+				}
+			case "body_capture":
+				tracing.BodyCapture = new(BodyCaptureConfig)
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "max_bytes":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						if tracing.BodyCapture.MaxBytes, err = strconv.Atoi(d.Val()); err != nil {
+							return
+						}
+					case "content_types":
+						tracing.BodyCapture.ContentTypes = append(tracing.BodyCapture.ContentTypes, d.RemainingArgs()...)
+					case "match":
+						var matcherSet caddy.ModuleMap
+						if matcherSet, err = parseMatcherSet(d); err != nil {
+							return
+						}
+						tracing.BodyCapture.MatchersRaw = append(tracing.BodyCapture.MatchersRaw, matcherSet)
+					default:
+						return d.Errf("unrecognized body_capture option '%s'", d.Val())
+					}
+				}
+			case "protocol_tags":
+				tracing.ProtocolTags = d.RemainingArgs()
+				if len(tracing.ProtocolTags) == 0 {
+					tracing.ProtocolTags = append([]string(nil), allProtocolTags...)
+				}
+			case "set_global":
+				tracing.SetGlobal = true
+			case "site_service_name":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				tracing.SiteServiceName = d.Val()
+				if d.NextArg() {
+					if tracing.MaxSiteTracers, err = strconv.Atoi(d.Val()); err != nil {
+						return
+					}
+				}
+			case "site_services":
+				tracing.SiteServices = append(tracing.SiteServices, d.RemainingArgs()...)
+			case "trusted_proxies":
+				tracing.TrustedProxies = append(tracing.TrustedProxies, d.RemainingArgs()...)
+			case "request_headers":
+				tracing.RequestHeaders = append(tracing.RequestHeaders, d.RemainingArgs()...)
+			case "response_headers":
+				tracing.ResponseHeaders = append(tracing.ResponseHeaders, d.RemainingArgs()...)
 			case "headers":
 				cfg.Headers = new(HeadersConfig)
 				for nesting := d.Nesting(); d.NextBlock(nesting); {
@@ -154,6 +458,8 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 							return d.ArgErr()
 						}
 						cfg.Headers.TraceBaggageHeaderPrefix = d.Val()
+					default:
+						return d.Errf("unrecognized headers option '%s'", d.Val())
 					}
 				}
 			case "baggage_restrictions":
@@ -171,9 +477,13 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 						if !d.NextArg() {
 							return d.ArgErr()
 						}
-						if cfg.BaggageRestrictions.RefreshInterval, err = time.ParseDuration(d.Val()); err != nil {
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
 							return
 						}
+						cfg.BaggageRestrictions.RefreshInterval = caddy.Duration(dur)
+					default:
+						return d.Errf("unrecognized baggage_restrictions option '%s'", d.Val())
 					}
 				}
 			case "throttler":
@@ -191,22 +501,58 @@ func (tracing *Opentracing) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err erro
 						if !d.NextArg() {
 							return d.ArgErr()
 						}
-						if cfg.Throttler.RefreshInterval, err = time.ParseDuration(d.Val()); err != nil {
+						var dur time.Duration
+						if dur, err = caddy.ParseDuration(d.Val()); err != nil {
 							return
 						}
+						cfg.Throttler.RefreshInterval = caddy.Duration(dur)
+					default:
+						return d.Errf("unrecognized throttler option '%s'", d.Val())
 					}
 				}
+			default:
+				return d.Errf("unrecognized subdirective '%s'", d.Val())
 			}
 		}
 	}
 	tracing.Config = cfg
-	fmt.Printf("%+v", cfg.Reporter)
 	return nil
 }
 
+// parseMatcherSet parses the block opened at the end of the current line, if
+// any, into a matcher set, the same way the Caddyfile does for named matchers.
+func parseMatcherSet(d *caddyfile.Dispenser) (caddy.ModuleMap, error) {
+	// in case there are multiple instances of the same matcher, concatenate
+	// their tokens, like the Caddyfile does for named matchers
+	tokensByMatcherName := make(map[string][]caddyfile.Token)
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		matcherName := d.Val()
+		tokensByMatcherName[matcherName] = append(tokensByMatcherName[matcherName], d.NextSegment()...)
+	}
+
+	matcherSet := make(caddy.ModuleMap)
+	for matcherName, tokens := range tokensByMatcherName {
+		mod, err := caddy.GetModule("http.matchers." + matcherName)
+		if err != nil {
+			return nil, d.Errf("getting matcher module '%s': %v", matcherName, err)
+		}
+		unm, ok := mod.New().(caddyfile.Unmarshaler)
+		if !ok {
+			return nil, d.Errf("matcher module '%s' is not a Caddyfile unmarshaler", matcherName)
+		}
+		if err = unm.UnmarshalCaddyfile(caddyfile.NewDispenser(tokens)); err != nil {
+			return nil, err
+		}
+		matcherSet[matcherName] = caddyconfig.JSON(unm, nil)
+	}
+	return matcherSet, nil
+}
+
 // Interface guard
 var (
 	_ caddyfile.Unmarshaler       = (*Opentracing)(nil)
 	_ caddyhttp.MiddlewareHandler = (*Opentracing)(nil)
 	_ caddy.Validator             = (*Opentracing)(nil)
+	_ caddy.Provisioner           = (*Opentracing)(nil)
+	_ caddy.CleanerUpper          = (*Opentracing)(nil)
 )
@@ -0,0 +1,206 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+// allOptions sets every option of the handler to a value other than the
+// zero value, though not necessarily a valid combination.
+const allOptions = `opentracing {
+	service_name gateway
+	env_overrides fill_missing
+	disabled
+	rpc_metrics
+	traceid_128bit
+	component_name my-gateway
+	operation_name "{http.request.method} {http.request.uri.path}"
+	skip {
+		path /healthz
+	}
+	upstream_spans
+	response_header X-Trace-Id
+	log_fields sampled_only
+	sampler {
+		type remote
+		param 0.5
+		sampling_server_url http://agent:5778/sampling
+		sampling_refresh_interval 1m
+		max_operations 100
+		operation_name_late_binding
+		strategies_file /etc/jaeger/strategies.json
+	}
+	reporter {
+		collector_endpoint http://collector:14268/api/traces
+		user alice
+		password secret
+		local_agent_host_port agent:6831
+		buffer_flush_interval 2s
+		attempt_reconnect_interval 30s
+		queue_size 500
+		log_spans
+		disable_attempt_reconnecting
+		http_headers {
+			X-Scope-OrgID tenant-1
+			X-Team platform
+		}
+	}
+	tail_sampling {
+		min_status 500
+		min_duration 2s
+		paths /checkout/*
+		probability 0.01
+		max_traces 100
+		max_spans_per_trace 50
+		decision_wait 10s
+	}
+	sampling_rules {
+		const 1 {
+			path /checkout/*
+		}
+	}
+	force_sample {
+		header X-Force-Trace
+		cookie force_trace
+		secret s3cr3t
+		max_age 5m
+	}
+	trust_context {
+		sources 10.0.0.0/8
+		match {
+			header X-Internal 1
+		}
+		untrusted drop
+		strip_headers
+	}
+	baggage {
+		allow tenant
+		max_key_length 64
+		max_value_length 256
+		max_total_size 1024
+		header tenant X-Tenant
+		tag tenant baggage.tenant
+	}
+	redact {
+		query_params token
+		path_patterns ^[0-9]+$
+		drop_query
+	}
+	body_capture {
+		max_bytes 2048
+		content_types application/json
+		match {
+			path /internal/*
+		}
+	}
+	protocol_tags protocol tls_version
+	set_global
+	site_service_name {http.request.host} 32
+	site_services example.com
+	trusted_proxies 192.168.0.0/16
+	request_headers Content-Type
+	response_headers Cache-Control
+	headers {
+		jaeger_debug_header x-debug-id
+		jaeger_baggage_header x-baggage
+		trace_context_header_name x-trace-id
+		trace_baggage_header_prefix x-ctx-
+	}
+	baggage_restrictions {
+		deny_baggage_on_initialization_failure
+		host_port agent:5778
+		refresh_interval 10s
+	}
+	throttler {
+		synchronous_initialization
+		host_port agent:5778
+		refresh_interval 5s
+	}
+}`
+
+// TestCaddyfileRoundTrip parses every option from the Caddyfile, and checks
+// that the JSON config sets all of them and decodes to the same handler.
+func TestCaddyfileRoundTrip(t *testing.T) {
+	var tracing Opentracing
+	if err := tracing.UnmarshalCaddyfile(caddyfile.NewTestDispenser(allOptions)); err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+	adapted, err := json.Marshal(&tracing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(adapted, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range zeroFields("", fields) {
+		t.Errorf("option %s is not set from the Caddyfile", path)
+	}
+
+	var decoded Opentracing
+	if err = json.Unmarshal(adapted, &decoded); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, tracing) {
+		t.Errorf("decoded handler differs:\n got %+v\nwant %+v", decoded, tracing)
+	}
+	encoded, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != string(adapted) {
+		t.Errorf("encoded config differs:\n got %s\nwant %s", encoded, adapted)
+	}
+}
+
+// zeroFields returns the paths of the zero values in v, decoded from JSON.
+func zeroFields(path string, v interface{}) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return []string{path}
+		}
+		var paths []string
+		for key, value := range v {
+			paths = append(paths, zeroFields(path+"."+key, value)...)
+		}
+		return paths
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{path}
+		}
+		var paths []string
+		for i, value := range v {
+			paths = append(paths, zeroFields(fmt.Sprintf("%s[%d]", path, i), value)...)
+		}
+		return paths
+	case nil:
+		return []string{path}
+	}
+	if reflect.ValueOf(v).IsZero() {
+		return []string{path}
+	}
+	return nil
+}
+
+func TestCaddyfileErrors(t *testing.T) {
+	for _, input := range []string{
+		"opentracing {\n\tunknown\n}",
+		"opentracing {\n\tdisable\n}",
+		"opentracing {\n\treporter {\n\t\tunknown 1\n\t}\n}",
+		"opentracing {\n\treporter {\n\t\thttp_headers {\n\t\t\tX-Missing-Value\n\t\t}\n\t}\n}",
+		"opentracing {\n\tsampler {\n\t\tparam one\n\t}\n}",
+		"opentracing {\n\tlog_fields always\n}",
+		"opentracing {\n\tservice_name\n}",
+	} {
+		var tracing Opentracing
+		if err := tracing.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err == nil {
+			t.Errorf("no error parsing %q", input)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package opentracing
 import (
 	"time"
 
+	"github.com/caddyserver/caddy/v2"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
@@ -82,7 +83,7 @@ type SamplerConfig struct {
 	// SamplingRefreshInterval controls how often the remotely controlled sampler will poll
 	// sampling manager for the appropriate sampling strategy.
 	// Can be provided by FromEnv() via the environment variable named JAEGER_SAMPLER_REFRESH_INTERVAL
-	SamplingRefreshInterval time.Duration `json:"sampling_refresh_interval"`
+	SamplingRefreshInterval caddy.Duration `json:"sampling_refresh_interval"`
 
 	// MaxOperations is the maximum number of operations that the PerOperationSampler
 	// will keep track of. If an operation is not tracked, a default probabilistic
@@ -98,6 +99,12 @@ type SamplerConfig struct {
 	//
 	// For backwards compatibility this option is off by defaulc.
 	OperationNameLateBinding bool `json:"operation_name_late_binding"`
+
+	// StrategiesFile is the path of a sampling strategies file in the format of the
+	// Jaeger collector, which the remote sampler uses in place of SamplingServerURL.
+	// The file is polled for changes every SamplingRefreshInterval.
+	// See https://www.jaegertracing.io/docs/latest/sampling/#file-sampling
+	StrategiesFile string `json:"strategies_file"`
 }
 
 // ReporterConfig is the config for opentracing reporter.
@@ -112,7 +119,7 @@ type ReporterConfig struct {
 	// BufferFlushInterval controls how often the buffer is force-flushed, even if it's not full.
 	// It is generally not useful, as it only matters for very low traffic services.
 	// Can be provided by FromEnv() via the environment variable named JAEGER_REPORTER_FLUSH_INTERVAL
-	BufferFlushInterval time.Duration `json:"buffer_flush_interval"`
+	BufferFlushInterval caddy.Duration `json:"buffer_flush_interval"`
 
 	// LogSpans, when true, enables LoggingReporter that runs in parallel with the main reporter
 	// and logs all submitted spans. Main Configuration.Logger must be initialized in the code
@@ -133,7 +140,7 @@ type ReporterConfig struct {
 	// AttemptReconnectInterval controls how often the agent client re-resolves the provided hostname
 	// in order to detect address changes. This option only applies if DisableAttemptReconnecting is false.
 	// Can be provided by FromEnv() via the environment variable named JAEGER_REPORTER_ATTEMPT_RECONNECT_INTERVAL
-	AttemptReconnectInterval time.Duration `json:"attempt_reconnect_interval"`
+	AttemptReconnectInterval caddy.Duration `json:"attempt_reconnect_interval"`
 
 	// CollectorEndpoint instructs reporter to send spans to jaeger-collector at this URL.
 	// Can be provided by FromEnv() via the environment variable named JAEGER_ENDPOINT
@@ -168,7 +175,7 @@ type BaggageRestrictionsConfig struct {
 
 	// RefreshInterval controls how often the baggage restriction manager will poll
 	// jaeger-agent for the most recent baggage restrictions.
-	RefreshInterval time.Duration `json:"refresh_interval"`
+	RefreshInterval caddy.Duration `json:"refresh_interval"`
 }
 
 // ThrottlerConfig configures the throttler which can be used to throttle the
@@ -180,7 +187,7 @@ type ThrottlerConfig struct {
 
 	// RefreshInterval controls how often the throttler will poll jaeger-agent
 	// for more throttling credits.
-	RefreshInterval time.Duration `json:"refresh_interval"`
+	RefreshInterval caddy.Duration `json:"refresh_interval"`
 
 	// SynchronousInitialization determines whether or not the throttler should
 	// synchronously fetch credits from the agent when an operation is seen for
@@ -194,9 +201,9 @@ type ThrottlerConfig struct {
 func (c *Config) ToTracingConfig() *config.Configuration {
 	ret := &config.Configuration{
 		ServiceName: c.ServiceName,
-		Disabled:    false,
+		Disabled:    c.Disabled,
 		RPCMetrics:  c.RPCMetrics,
-		Gen128Bit:   false,
+		Gen128Bit:   c.Gen128Bit,
 		Tags:        []opentracing.Tag{},
 	}
 	if c.Sampler != nil {
@@ -204,7 +211,7 @@ func (c *Config) ToTracingConfig() *config.Configuration {
 			Type:                     c.Sampler.Type,
 			Param:                    c.Sampler.Param,
 			SamplingServerURL:        c.Sampler.SamplingServerURL,
-			SamplingRefreshInterval:  c.Sampler.SamplingRefreshInterval,
+			SamplingRefreshInterval:  time.Duration(c.Sampler.SamplingRefreshInterval),
 			MaxOperations:            c.Sampler.MaxOperations,
 			OperationNameLateBinding: c.Sampler.OperationNameLateBinding,
 		}
@@ -212,11 +219,11 @@ func (c *Config) ToTracingConfig() *config.Configuration {
 	if c.Reporter != nil {
 		ret.Reporter = &config.ReporterConfig{
 			QueueSize:                  c.Reporter.QueueSize,
-			BufferFlushInterval:        c.Reporter.BufferFlushInterval,
+			BufferFlushInterval:        time.Duration(c.Reporter.BufferFlushInterval),
 			LogSpans:                   c.Reporter.LogSpans,
 			LocalAgentHostPort:         c.Reporter.LocalAgentHostPort,
 			DisableAttemptReconnecting: c.Reporter.DisableAttemptReconnecting,
-			AttemptReconnectInterval:   c.Reporter.AttemptReconnectInterval,
+			AttemptReconnectInterval:   time.Duration(c.Reporter.AttemptReconnectInterval),
 			CollectorEndpoint:          c.Reporter.CollectorEndpoint,
 			User:                       c.Reporter.User,
 			Password:                   c.Reporter.Password,
@@ -235,13 +242,13 @@ func (c *Config) ToTracingConfig() *config.Configuration {
 		ret.BaggageRestrictions = &config.BaggageRestrictionsConfig{
 			DenyBaggageOnInitializationFailure: c.BaggageRestrictions.DenyBaggageOnInitializationFailure,
 			HostPort:                           c.BaggageRestrictions.HostPort,
-			RefreshInterval:                    c.BaggageRestrictions.RefreshInterval,
+			RefreshInterval:                    time.Duration(c.BaggageRestrictions.RefreshInterval),
 		}
 	}
 	if c.Throttler != nil {
 		ret.Throttler = &config.ThrottlerConfig{
 			HostPort:                  c.Throttler.HostPort,
-			RefreshInterval:           c.Throttler.RefreshInterval,
+			RefreshInterval:           time.Duration(c.Throttler.RefreshInterval),
 			SynchronousInitialization: c.Throttler.SynchronousInitialization,
 		}
 	}
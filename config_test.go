@@ -0,0 +1,115 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/config"
+)
+
+func TestToTracingConfig(t *testing.T) {
+	var c Config
+	err := json.Unmarshal([]byte(`{
+		"service_name": "caddy-test",
+		"disabled": true,
+		"rpc_metrics": true,
+		"traceid_128bit": true,
+		"sampler": {
+			"type": "remote",
+			"param": 0.5,
+			"sampling_server_url": "http://agent:5778/sampling",
+			"sampling_refresh_interval": "1m",
+			"max_operations": 100,
+			"operation_name_late_binding": true
+		},
+		"reporter": {
+			"queue_size": 500,
+			"buffer_flush_interval": "2s",
+			"log_spans": true,
+			"local_agent_host_port": "agent:6831",
+			"disable_attempt_reconnecting": true,
+			"attempt_reconnect_interval": "30s",
+			"collector_endpoint": "http://collector:14268/api/traces",
+			"user": "user",
+			"password": "password",
+			"http_headers": {"X-Tenant": "acme"}
+		},
+		"headers": {
+			"jaeger_debug_header": "x-debug-id",
+			"jaeger_baggage_header": "x-baggage",
+			"trace_context_header_name": "x-trace-id",
+			"trace_baggage_header_prefix": "x-ctx-"
+		},
+		"baggage_restrictions": {
+			"deny_baggage_on_initialization_failure": true,
+			"host_port": "agent:5778",
+			"refresh_interval": "10s"
+		},
+		"throttler": {
+			"host_port": "agent:5778",
+			"refresh_interval": "5s",
+			"synchronous_initialization": true
+		}
+	}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &config.Configuration{
+		ServiceName: "caddy-test",
+		Disabled:    true,
+		RPCMetrics:  true,
+		Gen128Bit:   true,
+		Tags:        []opentracing.Tag{},
+		Sampler: &config.SamplerConfig{
+			Type:                     "remote",
+			Param:                    0.5,
+			SamplingServerURL:        "http://agent:5778/sampling",
+			SamplingRefreshInterval:  time.Minute,
+			MaxOperations:            100,
+			OperationNameLateBinding: true,
+		},
+		Reporter: &config.ReporterConfig{
+			QueueSize:                  500,
+			BufferFlushInterval:        2 * time.Second,
+			LogSpans:                   true,
+			LocalAgentHostPort:         "agent:6831",
+			DisableAttemptReconnecting: true,
+			AttemptReconnectInterval:   30 * time.Second,
+			CollectorEndpoint:          "http://collector:14268/api/traces",
+			User:                       "user",
+			Password:                   "password",
+			HTTPHeaders:                map[string]string{"X-Tenant": "acme"},
+		},
+		Headers: &jaeger.HeadersConfig{
+			JaegerDebugHeader:        "x-debug-id",
+			JaegerBaggageHeader:      "x-baggage",
+			TraceContextHeaderName:   "x-trace-id",
+			TraceBaggageHeaderPrefix: "x-ctx-",
+		},
+		BaggageRestrictions: &config.BaggageRestrictionsConfig{
+			DenyBaggageOnInitializationFailure: true,
+			HostPort:                           "agent:5778",
+			RefreshInterval:                    10 * time.Second,
+		},
+		Throttler: &config.ThrottlerConfig{
+			HostPort:                  "agent:5778",
+			RefreshInterval:           5 * time.Second,
+			SynchronousInitialization: true,
+		},
+	}
+	if got := c.ToTracingConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToTracingConfig() = %+v, want %+v", got, want)
+	}
+}
+
+func TestToTracingConfigEmpty(t *testing.T) {
+	want := &config.Configuration{Tags: []opentracing.Tag{}}
+	if got := (&Config{}).ToTracingConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToTracingConfig() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package opentracing
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/uber/jaeger-client-go/config"
+	"go.uber.org/zap"
+)
+
+// How the JAEGER_* environment variables apply to the tracer config.
+const (
+	envOverride    = "override"
+	envFillMissing = "fill_missing"
+	envIgnore      = "ignore"
+)
+
+// applyEnv applies the JAEGER_* environment variables to cfg according to
+// the env_overrides mode.
+func applyEnv(cfg *config.Configuration, mode string) (*config.Configuration, error) {
+	switch mode {
+	case "", envOverride:
+		return cfg.FromEnv()
+	case envFillMissing:
+		env, err := new(config.Configuration).FromEnv()
+		if err != nil {
+			return nil, err
+		}
+		fillMissing(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(env).Elem())
+		return cfg, nil
+	case envIgnore:
+		return cfg, nil
+	}
+	return nil, fmt.Errorf("unknown env_overrides mode (%s)", mode)
+}
+
+// fillMissing sets the zero fields of the struct dst to the fields of src,
+// recursing into the nested configs. Empty slices and maps are missing too.
+// Booleans are never missing, since a false one cannot be told apart from
+// one left out, e.g. disabled false must not be turned on by JAEGER_DISABLED.
+func fillMissing(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		d, s := dst.Field(i), src.Field(i)
+		if !d.CanSet() {
+			continue
+		}
+		switch {
+		case d.Kind() == reflect.Ptr && d.Type().Elem().Kind() == reflect.Struct && !d.IsNil():
+			if !s.IsNil() {
+				fillMissing(d.Elem(), s.Elem())
+			}
+		case d.Kind() == reflect.Bool:
+		case d.IsZero(), (d.Kind() == reflect.Slice || d.Kind() == reflect.Map) && d.Len() == 0:
+			d.Set(s)
+		}
+	}
+}
+
+// configFields returns the log fields describing cfg, without its secrets.
+func configFields(cfg *config.Configuration) []zap.Field {
+	fields := []zap.Field{
+		zap.String("service_name", cfg.ServiceName),
+		zap.Bool("disabled", cfg.Disabled),
+		zap.Bool("rpc_metrics", cfg.RPCMetrics),
+		zap.Bool("traceid_128bit", cfg.Gen128Bit),
+	}
+	if len(cfg.Tags) > 0 {
+		tags := make([]string, len(cfg.Tags))
+		for i, tag := range cfg.Tags {
+			tags[i] = fmt.Sprintf("%s=%v", tag.Key, tag.Value)
+		}
+		fields = append(fields, zap.Strings("tags", tags))
+	}
+	if s := cfg.Sampler; s != nil {
+		fields = append(fields,
+			zap.String("sampler_type", s.Type),
+			zap.Float64("sampler_param", s.Param),
+			zap.String("sampling_server_url", redactedURL(s.SamplingServerURL)),
+			zap.Duration("sampling_refresh_interval", s.SamplingRefreshInterval),
+		)
+	}
+	if r := cfg.Reporter; r != nil {
+		fields = append(fields,
+			zap.String("local_agent_host_port", r.LocalAgentHostPort),
+			zap.String("collector_endpoint", redactedURL(r.CollectorEndpoint)),
+			zap.String("user", r.User),
+			zap.Bool("password_set", r.Password != ""),
+			zap.Int("queue_size", r.QueueSize),
+			zap.Duration("buffer_flush_interval", r.BufferFlushInterval),
+			zap.Bool("log_spans", r.LogSpans),
+		)
+		if len(r.HTTPHeaders) > 0 {
+			// the values of the headers may be credentials
+			names := make([]string, 0, len(r.HTTPHeaders))
+			for name := range r.HTTPHeaders {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fields = append(fields, zap.Strings("reporter_http_headers", names))
+		}
+	}
+	if b := cfg.BaggageRestrictions; b != nil {
+		fields = append(fields, zap.String("baggage_restrictions_host_port", b.HostPort))
+	}
+	if t := cfg.Throttler; t != nil {
+		fields = append(fields, zap.String("throttler_host_port", t.HostPort))
+	}
+	return fields
+}
+
+// redactedURL hides the password of a URL.
+func redactedURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,75 @@
+package opentracing
+
+import (
+	"testing"
+
+	"github.com/uber/jaeger-client-go/config"
+)
+
+func TestApplyEnv(t *testing.T) {
+	setenv(t, "JAEGER_SAMPLER_TYPE", "probabilistic")
+	setenv(t, "JAEGER_SAMPLER_PARAM", "0.5")
+	setenv(t, "JAEGER_AGENT_HOST", "agent")
+	setenv(t, "JAEGER_AGENT_PORT", "6831")
+	setenv(t, "JAEGER_DISABLED", "true")
+
+	for _, tc := range []struct {
+		mode     string
+		sampler  string
+		param    float64
+		agent    string
+		disabled bool
+	}{
+		{mode: envOverride, sampler: "probabilistic", param: 0.5, agent: "agent:6831", disabled: true},
+		// the configured sampler and enabled tracer are kept
+		{mode: envFillMissing, sampler: "const", param: 1, agent: "agent:6831"},
+		{mode: envIgnore, sampler: "const", param: 1},
+	} {
+		cfg := &config.Configuration{Sampler: &config.SamplerConfig{Type: "const", Param: 1}}
+		cfg, err := applyEnv(cfg, tc.mode)
+		if err != nil {
+			t.Errorf("%s: %v", tc.mode, err)
+			continue
+		}
+		if cfg.Sampler.Type != tc.sampler || cfg.Sampler.Param != tc.param {
+			t.Errorf("%s: sampler %s %v, want %s %v", tc.mode, cfg.Sampler.Type, cfg.Sampler.Param, tc.sampler, tc.param)
+		}
+		var agent string
+		if cfg.Reporter != nil {
+			agent = cfg.Reporter.LocalAgentHostPort
+		}
+		if agent != tc.agent {
+			t.Errorf("%s: agent %q, want %q", tc.mode, agent, tc.agent)
+		}
+		if cfg.Disabled != tc.disabled {
+			t.Errorf("%s: disabled %v, want %v", tc.mode, cfg.Disabled, tc.disabled)
+		}
+	}
+
+	if _, err := applyEnv(new(config.Configuration), "sometimes"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestEnvServiceName(t *testing.T) {
+	setenv(t, "JAEGER_SERVICE_NAME", "from-env")
+	for _, tc := range []struct {
+		mode        string
+		serviceName string
+		want        string
+	}{
+		{mode: envOverride, serviceName: "caddy-configured", want: "caddy-configured"},
+		{mode: envFillMissing, serviceName: "caddy-configured", want: "caddy-configured"},
+		{mode: envOverride, want: "from-env"},
+		{mode: envFillMissing, want: "from-env"},
+		{mode: envIgnore, want: defaultServiceName},
+	} {
+		tracing := provision(t, &Opentracing{
+			Config:       Config{ServiceName: tc.serviceName, Sampler: constSampler(true)},
+			EnvOverrides: tc.mode,
+		})
+		if tracing.serviceName != tc.want {
+			t.Errorf("%s %q: service name %q, want %q", tc.mode, tc.serviceName, tracing.serviceName, tc.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package opentracing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+const defaultForceSampleMaxAge = 5 * time.Minute
+
+// ForceSampleConfig configures a trigger which forces a request to be
+// sampled, in place of the Jaeger debug header that any client can set.
+// The trigger is a token of the form `<unix timestamp>.<signature>`, where
+// the signature is the hex encoded HMAC-SHA256 of the timestamp with the
+// secret. Tokens which are malformed, expired or wrongly signed are ignored.
+type ForceSampleConfig struct {
+	// Header is the name of the request header carrying the token.
+	Header string `json:"header"`
+
+	// Cookie is the name of the cookie carrying the token.
+	Cookie string `json:"cookie"`
+
+	// Secret is the key of the signature. It supports global placeholders
+	// and {file.*}, e.g. {env.TRACING_SECRET}, to keep it out of the
+	// configuration.
+	Secret string `json:"secret"`
+
+	// MaxAge is how far the timestamp of a token may be from the current
+	// time. Default 5m.
+	MaxAge caddy.Duration `json:"max_age"`
+}
+
+// forceSampler verifies the force-sample tokens of requests.
+type forceSampler struct {
+	header string
+	cookie string
+	secret []byte
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+func newForceSampler(cfg ForceSampleConfig) (*forceSampler, error) {
+	if cfg.Header == "" && cfg.Cookie == "" {
+		return nil, fmt.Errorf("a header or a cookie is required")
+	}
+	secret, err := expandValue(cfg.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("secret: %v", err)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("a secret is required")
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = caddy.Duration(defaultForceSampleMaxAge)
+	}
+	tracingMetrics.init.Do(initTracingMetrics)
+	return &forceSampler{
+		header: cfg.Header,
+		cookie: cfg.Cookie,
+		secret: []byte(secret),
+		maxAge: time.Duration(cfg.MaxAge),
+		now:    time.Now,
+	}, nil
+}
+
+// forced reports whether r carries a valid token.
+func (fs *forceSampler) forced(r *http.Request) bool {
+	var token string
+	if fs.header != "" {
+		token = r.Header.Get(fs.header)
+	}
+	if token == "" && fs.cookie != "" {
+		if c, err := r.Cookie(fs.cookie); err == nil {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		return false
+	}
+
+	result := fs.verify(token)
+	tracingMetrics.forceSample.WithLabelValues(result).Inc()
+	return result == "accepted"
+}
+
+// verify checks token and returns the result to count it under.
+func (fs *forceSampler) verify(token string) string {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return "malformed"
+	}
+	timestamp, err := strconv.ParseInt(token[:i], 10, 64)
+	if err != nil {
+		return "malformed"
+	}
+	signature, err := hex.DecodeString(token[i+1:])
+	if err != nil {
+		return "malformed"
+	}
+
+	age := fs.now().Sub(time.Unix(timestamp, 0))
+	if age > fs.maxAge || age < -fs.maxAge {
+		return "expired"
+	}
+
+	mac := hmac.New(sha256.New, fs.secret)
+	mac.Write([]byte(token[:i]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "invalid_signature"
+	}
+	return "accepted"
+}
@@ -0,0 +1,138 @@
+package opentracing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/uber/jaeger-client-go"
+)
+
+// forceSampleToken returns a token for the timestamp signed with secret.
+func forceSampleToken(secret string, timestamp time.Time) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	return ts + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestForceSampler(t *testing.T, now time.Time) *forceSampler {
+	t.Helper()
+	fs, err := newForceSampler(ForceSampleConfig{
+		Header: "X-Force-Trace",
+		Cookie: "force_trace",
+		Secret: "s3cr3t",
+		MaxAge: caddy.Duration(5 * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.now = func() time.Time { return now }
+	return fs
+}
+
+func TestForceSampler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fs := newTestForceSampler(t, now)
+	valid := forceSampleToken("s3cr3t", now)
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		cookie string
+		want   string // the result counted, or "" when none
+	}{
+		{name: "none"},
+		{name: "valid header", header: valid, want: "accepted"},
+		{name: "valid cookie", cookie: valid, want: "accepted"},
+		{name: "slightly old", header: forceSampleToken("s3cr3t", now.Add(-4*time.Minute)), want: "accepted"},
+		{name: "no dot", header: "1700000000", want: "malformed"},
+		{name: "bad timestamp", header: "17e8." + valid[11:], want: "malformed"},
+		{name: "non-hex signature", header: "1700000000.xyz", want: "malformed"},
+		{name: "expired", header: forceSampleToken("s3cr3t", now.Add(-6*time.Minute)), want: "expired"},
+		{name: "future", header: forceSampleToken("s3cr3t", now.Add(6*time.Minute)), want: "expired"},
+		{name: "wrong secret", header: forceSampleToken("other", now), want: "invalid_signature"},
+		{name: "truncated signature", header: valid[:len(valid)-2], want: "invalid_signature"},
+		// the header wins over the cookie
+		{name: "header before cookie", header: "bad", cookie: valid, want: "malformed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			counts := make(map[string]float64)
+			for _, result := range []string{"accepted", "malformed", "expired", "invalid_signature"} {
+				counts[result] = testutil.ToFloat64(tracingMetrics.forceSample.WithLabelValues(result))
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("X-Force-Trace", tc.header)
+			}
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "force_trace", Value: tc.cookie})
+			}
+			if got := fs.forced(r); got != (tc.want == "accepted") {
+				t.Errorf("forced = %v, want %v", got, tc.want == "accepted")
+			}
+
+			for result, count := range counts {
+				want := 0.0
+				if result == tc.want {
+					want = 1
+				}
+				if d := testutil.ToFloat64(tracingMetrics.forceSample.WithLabelValues(result)) - count; d != want {
+					t.Errorf("%s tokens increased by %v, want %v", result, d, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewForceSampler(t *testing.T) {
+	for _, cfg := range []ForceSampleConfig{
+		{Secret: "s3cr3t"},
+		{Header: "X-Force-Trace"},
+		{Header: "X-Force-Trace", Secret: "{file./does/not/exist}"},
+	} {
+		if _, err := newForceSampler(cfg); err == nil {
+			t.Errorf("newForceSampler(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestForceSampleServeHTTP(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	defer SetTestReporter(reporter)()
+	tracing := provision(t, &Opentracing{
+		Config:       Config{Sampler: constSampler(false)},
+		EnvOverrides: envIgnore,
+		ForceSample:  &ForceSampleConfig{Header: "X-Force-Trace", Secret: "s3cr3t"},
+	})
+
+	// the debug header cannot force sampling any more, nor reach upstreams
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Jaeger-Debug-Id", "debug")
+	var debugID string
+	serve(t, tracing, r, func(w http.ResponseWriter, r *http.Request) error {
+		debugID = r.Header.Get("Jaeger-Debug-Id")
+		return nil
+	})
+	if debugID != "" {
+		t.Errorf("Jaeger-Debug-Id passed on as %q", debugID)
+	}
+	if n := reporter.SpansSubmitted(); n != 0 {
+		t.Errorf("%d spans reported with the debug header, want 0", n)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Force-Trace", forceSampleToken("s3cr3t", time.Now()))
+	serve(t, tracing, r, nopHandler)
+	if n := reporter.SpansSubmitted(); n != 1 {
+		t.Errorf("%d spans reported with a valid token, want 1", n)
+	}
+}
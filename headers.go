@@ -0,0 +1,42 @@
+package opentracing
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	requestHeaderTagPrefix  = "http.request.header."
+	responseHeaderTagPrefix = "http.response.header."
+)
+
+// deniedHeaders are never recorded as tags, since they carry credentials.
+var deniedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// capturedHeader is a header recorded as a span tag.
+type capturedHeader struct {
+	name string
+	tag  string
+}
+
+// capturedHeaders returns the headers to record under the tag prefix,
+// leaving out the denied ones.
+func capturedHeaders(names []string, prefix string) []capturedHeader {
+	var headers []capturedHeader
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		if deniedHeaders[name] {
+			continue
+		}
+		headers = append(headers, capturedHeader{
+			name: name,
+			tag:  prefix + strings.ToLower(name),
+		})
+	}
+	return headers
+}
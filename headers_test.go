@@ -0,0 +1,79 @@
+package opentracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCapturedHeaders(t *testing.T) {
+	got := capturedHeaders([]string{"x-request-id", "Authorization", "proxy-authorization", "COOKIE", "Set-Cookie"}, requestHeaderTagPrefix)
+	want := []capturedHeader{{name: "X-Request-Id", tag: "http.request.header.x-request-id"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("captured %+v, want %+v", got, want)
+	}
+}
+
+func TestHeaderTags(t *testing.T) {
+	tracing := &Opentracing{
+		RequestHeaders:  []string{"X-Request-Id", "Authorization", "Cookie"},
+		ResponseHeaders: []string{"X-Cache", "Set-Cookie"},
+	}
+	tr := provisionMock(t, tracing)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=secret")
+	serve(t, tracing, r, func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusOK)
+		// too late, the header was written
+		w.Header().Set("X-Cache", "MISS")
+		return nil
+	})
+
+	tags := tr.FinishedSpans()[0].Tags()
+	if got := tags["http.request.header.x-request-id"]; got != "abc" {
+		t.Errorf("x-request-id tag = %v, want abc", got)
+	}
+	if got := tags["http.response.header.x-cache"]; got != "HIT" {
+		t.Errorf("x-cache tag = %v, want HIT", got)
+	}
+	for _, denied := range []string{"http.request.header.authorization", "http.request.header.cookie", "http.response.header.set-cookie"} {
+		if got, ok := tags[denied]; ok {
+			t.Errorf("%s tag = %v, want none", denied, got)
+		}
+	}
+}
+
+func TestReadHeadersOnWrite(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(w http.ResponseWriter)
+	}{
+		{name: "implicit header", write: func(w http.ResponseWriter) {
+			w.Header().Set("X-Cache", "HIT")
+			w.Write([]byte("ok"))
+		}},
+		{name: "early hints", write: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+			// the final response still sets it
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(http.StatusOK)
+		}},
+	} {
+		mt := &metricsTracker{
+			ResponseWriter: httptest.NewRecorder(),
+			captureHeaders: capturedHeaders([]string{"X-Cache"}, responseHeaderTagPrefix),
+		}
+		tc.write(mt)
+		mt.Header().Set("X-Cache", "MISS")
+		mt.Write([]byte("more"))
+		if !reflect.DeepEqual(mt.headerValues, []string{"HIT"}) {
+			t.Errorf("%s: header values %q, want HIT", tc.name, mt.headerValues)
+		}
+	}
+}
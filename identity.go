@@ -0,0 +1,60 @@
+package opentracing
+
+import (
+	"github.com/caddyserver/caddy/v2"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// spanIdentity returns the trace ID and span ID of sp together with its
+// sampling decision. ok is false when the tracer does not expose them,
+// e.g. when tracing is disabled and the noop tracer is in use.
+func spanIdentity(sp opentracing.Span) (traceID, spanID string, sampled, ok bool) {
+	return contextIdentity(sp.Context())
+}
+
+// contextIdentity is like spanIdentity, for a span context.
+func contextIdentity(sc opentracing.SpanContext) (traceID, spanID string, sampled, ok bool) {
+	if jsc, isJaeger := sc.(jaeger.SpanContext); isJaeger && jsc.IsValid() {
+		return jsc.TraceID().String(), jsc.SpanID().String(), jsc.IsSampled(), true
+	}
+	return "", "", false, false
+}
+
+// continuesTrace reports whether sc carries a trace to continue, rather
+// than only baggage or a debug ID, which jaeger extracts too.
+func continuesTrace(sc opentracing.SpanContext) bool {
+	if jsc, isJaeger := sc.(jaeger.SpanContext); isJaeger {
+		return jsc.IsValid()
+	}
+	return sc != nil
+}
+
+// contextSampling returns the sampling decision of the span of sc. dropped
+// is true when the span is not sampled and the decision is final, so that
+// its tags would be discarded anyway.
+func contextSampling(sc opentracing.SpanContext) (sampled, dropped bool) {
+	if jsc, isJaeger := sc.(jaeger.SpanContext); isJaeger && jsc.IsValid() {
+		return jsc.IsSampled(), !jsc.IsSampled() && jsc.IsSamplingFinalized()
+	}
+	return false, false
+}
+
+// spanPlaceholders returns the {http.opentracing.*} placeholders of sp,
+// formatted only when they are used.
+func spanPlaceholders(sp opentracing.Span) caddy.ReplacerFunc {
+	return func(key string) (interface{}, bool) {
+		switch key {
+		case "http.opentracing.trace_id":
+			traceID, _, _, ok := spanIdentity(sp)
+			return traceID, ok
+		case "http.opentracing.span_id":
+			_, spanID, _, ok := spanIdentity(sp)
+			return spanID, ok
+		case "http.opentracing.sampled":
+			_, _, sampled, ok := spanIdentity(sp)
+			return sampled, ok
+		}
+		return nil, false
+	}
+}
@@ -0,0 +1,97 @@
+package opentracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	caddyopentracing "github.com/n0trace/caddy-opentracing"
+	"github.com/n0trace/caddy-opentracing/tracingtest"
+	jaegerthrift "github.com/uber/jaeger-client-go/thrift-gen/jaeger"
+)
+
+// serveAndFlush provisions tracing, serves a request to path through it,
+// then cleans it up, which flushes the reporter.
+func serveAndFlush(t *testing.T, tracing *caddyopentracing.Opentracing, path string) {
+	t.Helper()
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	if err := tracing.Provision(ctx); err != nil {
+		t.Fatalf("provisioning: %v", err)
+	}
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusTeapot)
+		return nil
+	})
+	if err := tracing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil), next); err != nil {
+		t.Fatalf("serving: %v", err)
+	}
+	if err := tracing.Cleanup(); err != nil {
+		t.Fatalf("cleaning up: %v", err)
+	}
+}
+
+// assertSpan checks the operation name and status code of the span, and
+// the service name of its batch.
+func assertSpan(t *testing.T, batches []*jaegerthrift.Batch, operationName string) {
+	t.Helper()
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			if span.OperationName != operationName {
+				continue
+			}
+			if got := batch.Process.ServiceName; got != "integration" {
+				t.Errorf("service name = %q, want integration", got)
+			}
+			for _, tag := range span.Tags {
+				if tag.Key == "http.status_code" {
+					if tag.VLong == nil || *tag.VLong != http.StatusTeapot {
+						t.Errorf("status code tag = %v, want %d", tag, http.StatusTeapot)
+					}
+					return
+				}
+			}
+			t.Errorf("span %q has no status code tag", operationName)
+			return
+		}
+	}
+	t.Errorf("no span named %q", operationName)
+}
+
+func TestAgentReporter(t *testing.T) {
+	agent := tracingtest.StartAgent(t)
+	serveAndFlush(t, &caddyopentracing.Opentracing{
+		Config: caddyopentracing.Config{
+			ServiceName: "integration",
+			Sampler:     &caddyopentracing.SamplerConfig{Type: "const", Param: 1},
+			Reporter: &caddyopentracing.ReporterConfig{
+				LocalAgentHostPort:         agent.HostPort(),
+				DisableAttemptReconnecting: true,
+			},
+		},
+		EnvOverrides: "ignore",
+	}, "/agent")
+
+	agent.WaitForSpans(t, 1)
+	assertSpan(t, agent.Batches(), "GET /agent")
+}
+
+func TestCollectorReporter(t *testing.T) {
+	collector := tracingtest.StartCollector(t)
+	serveAndFlush(t, &caddyopentracing.Opentracing{
+		Config: caddyopentracing.Config{
+			ServiceName: "integration",
+			Sampler:     &caddyopentracing.SamplerConfig{Type: "const", Param: 1},
+			Reporter: &caddyopentracing.ReporterConfig{
+				CollectorEndpoint: collector.Endpoint(),
+			},
+		},
+		EnvOverrides: "ignore",
+	}, "/collector")
+
+	collector.WaitForSpans(t, 1)
+	assertSpan(t, collector.Batches(), "GET /collector")
+}
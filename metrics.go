@@ -0,0 +1,47 @@
+package opentracing
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var tracingMetrics = struct {
+	init             sync.Once
+	tailTraces       *prometheus.CounterVec
+	tailDroppedSpans prometheus.Counter
+	tailBuffered     prometheus.Gauge
+	forceSample      *prometheus.CounterVec
+}{
+	init: sync.Once{},
+}
+
+func initTracingMetrics() {
+	const ns, sub = "caddy", "opentracing"
+
+	tracingMetrics.tailTraces = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "tail_traces_total",
+		Help:      "Counter of traces handled by tail sampling, by decision.",
+	}, []string{"decision"})
+	tracingMetrics.tailDroppedSpans = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "tail_dropped_spans_total",
+		Help:      "Counter of spans dropped because their trace buffer was full.",
+	})
+	tracingMetrics.tailBuffered = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "tail_buffered_traces",
+		Help:      "Number of traces currently buffered by tail sampling.",
+	})
+	tracingMetrics.forceSample = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "force_sample_tokens_total",
+		Help:      "Counter of force-sample tokens received, by verification result.",
+	}, []string{"result"})
+}
@@ -0,0 +1,71 @@
+package opentracing
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+const (
+	clientIPKey = "http.client_ip"
+	hostNameKey = "host.name"
+)
+
+// setPeerTags records the address of the connection peer of r, the client
+// IP address resolved through the trusted proxies and the host name.
+func (tracing *Opentracing) setPeerTags(sp opentracing.Span, r *http.Request) {
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if ip4 := peer.To4(); ip4 != nil {
+		ext.PeerHostIPv4.SetString(sp, ip4.String())
+	} else if peer != nil {
+		ext.PeerHostIPv6.Set(sp, peer.String())
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+		ext.PeerPort.Set(sp, uint16(p))
+	}
+	if client := tracing.clientIP(r, peer); client != nil {
+		sp.SetTag(clientIPKey, client.String())
+	}
+	if tracing.hostName != "" {
+		sp.SetTag(hostNameKey, tracing.hostName)
+	}
+}
+
+// clientIP returns the IP address of the client of r. When the peer is a
+// trusted proxy, it is the rightmost address of X-Forwarded-For which is not
+// a trusted proxy itself.
+func (tracing *Opentracing) clientIP(r *http.Request, peer net.IP) net.IP {
+	if peer == nil || !tracing.trustedProxy(peer) {
+		return peer
+	}
+	client := peer
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !tracing.trustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
+
+func (tracing *Opentracing) trustedProxy(ip net.IP) bool {
+	for _, ipNet := range tracing.proxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package opentracing
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+func TestClientIP(t *testing.T) {
+	tracing := provision(t, &Opentracing{
+		Config:         Config{Sampler: constSampler(true)},
+		EnvOverrides:   envIgnore,
+		TrustedProxies: []string{"10.0.0.0/8", "2001:db8::/32"},
+	})
+
+	for _, tc := range []struct {
+		name      string
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{name: "untrusted peer", peer: "203.0.113.1", forwarded: []string{"198.51.100.1"}, want: "203.0.113.1"},
+		{name: "no header", peer: "10.0.0.1", want: "10.0.0.1"},
+		{name: "trusted peer", peer: "10.0.0.1", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted chain", peer: "10.0.0.1", forwarded: []string{"198.51.100.2, 198.51.100.1, 10.0.0.3, 10.0.0.2"}, want: "198.51.100.1"},
+		{name: "multiple headers", peer: "10.0.0.1", forwarded: []string{"198.51.100.2, 198.51.100.1", "10.0.0.2"}, want: "198.51.100.1"},
+		{name: "all trusted", peer: "10.0.0.1", forwarded: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "invalid entry", peer: "10.0.0.1", forwarded: []string{"198.51.100.1, junk, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "invalid last entry", peer: "10.0.0.1", forwarded: []string{"198.51.100.1, junk"}, want: "10.0.0.1"},
+		{name: "trusted IPv6 peer", peer: "2001:db8::1", forwarded: []string{"2001:db8:ffff::1, 2001:db8::2"}, want: "2001:db8:ffff::1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, forwarded := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if got := tracing.clientIP(r, net.ParseIP(tc.peer)); got.String() != tc.want {
+				t.Errorf("client IP %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPeerTags(t *testing.T) {
+	for _, tc := range []struct {
+		remoteAddr string
+		wantTags   map[string]interface{}
+		wantAbsent string
+	}{
+		{
+			remoteAddr: "203.0.113.1:1234",
+			wantTags: map[string]interface{}{
+				string(ext.PeerHostIPv4): "203.0.113.1",
+				string(ext.PeerPort):     uint16(1234),
+				clientIPKey:              "203.0.113.1",
+			},
+			wantAbsent: string(ext.PeerHostIPv6),
+		},
+		{
+			remoteAddr: "[2001:db8::1]:1234",
+			wantTags: map[string]interface{}{
+				string(ext.PeerHostIPv6): "2001:db8::1",
+				string(ext.PeerPort):     uint16(1234),
+				clientIPKey:              "2001:db8::1",
+			},
+			wantAbsent: string(ext.PeerHostIPv4),
+		},
+	} {
+		t.Run(tc.remoteAddr, func(t *testing.T) {
+			tracing := &Opentracing{}
+			tr := provisionMock(t, tracing)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			serve(t, tracing, r, nopHandler)
+
+			sp := tr.FinishedSpans()[0]
+			for key, want := range tc.wantTags {
+				if got := sp.Tag(key); got != want {
+					t.Errorf("%s = %#v, want %#v", key, got, want)
+				}
+			}
+			if got, ok := sp.Tags()[tc.wantAbsent]; ok {
+				t.Errorf("%s = %#v, want none", tc.wantAbsent, got)
+			}
+		})
+	}
+}
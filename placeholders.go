@@ -0,0 +1,79 @@
+package opentracing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/uber/jaeger-client-go/config"
+)
+
+// filePlaceholderPrefix is the prefix of the {file.*} placeholders, which
+// expand to the content of a file, e.g. {file./run/secrets/jaeger}.
+const filePlaceholderPrefix = "file."
+
+// expandValue expands the global placeholders, e.g. {env.JAEGER_PASSWORD},
+// and the {file.*} placeholders of a config value. The unknown placeholders
+// are left as is.
+func expandValue(s string) (string, error) {
+	if !strings.Contains(s, "{") {
+		return s, nil
+	}
+	var fileErr error
+	repl := caddy.NewReplacer()
+	repl.Map(func(key string) (interface{}, bool) {
+		if !strings.HasPrefix(key, filePlaceholderPrefix) {
+			return nil, false
+		}
+		content, err := ioutil.ReadFile(strings.TrimPrefix(key, filePlaceholderPrefix))
+		if err != nil {
+			fileErr = err
+			return nil, false
+		}
+		// secret files usually end with a newline
+		return strings.TrimRight(string(content), "\r\n"), true
+	})
+	s = repl.ReplaceKnown(s, "")
+	return s, fileErr
+}
+
+// expandPlaceholders expands the placeholders of the string values of cfg.
+func expandPlaceholders(cfg *config.Configuration) error {
+	values := []*string{&cfg.ServiceName}
+	if cfg.Sampler != nil {
+		values = append(values, &cfg.Sampler.SamplingServerURL)
+	}
+	if cfg.Reporter != nil {
+		values = append(values,
+			&cfg.Reporter.LocalAgentHostPort,
+			&cfg.Reporter.CollectorEndpoint,
+			&cfg.Reporter.User,
+			&cfg.Reporter.Password,
+		)
+		// the map is shared with the handler config, which keeps the raw values
+		headers := make(map[string]string, len(cfg.Reporter.HTTPHeaders))
+		for name, value := range cfg.Reporter.HTTPHeaders {
+			expanded, err := expandValue(value)
+			if err != nil {
+				return fmt.Errorf("reporter header %s: %v", name, err)
+			}
+			headers[name] = expanded
+		}
+		cfg.Reporter.HTTPHeaders = headers
+	}
+	if cfg.BaggageRestrictions != nil {
+		values = append(values, &cfg.BaggageRestrictions.HostPort)
+	}
+	if cfg.Throttler != nil {
+		values = append(values, &cfg.Throttler.HostPort)
+	}
+	for _, v := range values {
+		expanded, err := expandValue(*v)
+		if err != nil {
+			return err
+		}
+		*v = expanded
+	}
+	return nil
+}
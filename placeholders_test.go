@@ -0,0 +1,68 @@
+package opentracing
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandValue(t *testing.T) {
+	setenv(t, "CADDY_OPENTRACING_TEST_USER", "alice")
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for in, want := range map[string]string{
+		"plain":                                  "plain",
+		"{env.CADDY_OPENTRACING_TEST_USER}":      "alice",
+		"user-{env.CADDY_OPENTRACING_TEST_USER}": "user-alice",
+		"{file." + secret + "}":                  "s3cr3t",
+		"{unknown}":                              "{unknown}",
+	} {
+		got, err := expandValue(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if got != want {
+			t.Errorf("%s expanded to %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := expandValue("{file." + filepath.Join(t.TempDir(), "missing") + "}"); err == nil {
+		t.Error("missing file expanded")
+	}
+}
+
+func TestExpandPlaceholdersHeaders(t *testing.T) {
+	setenv(t, "CADDY_OPENTRACING_TEST_TENANT", "tenant-1")
+	c := &Config{
+		ServiceName: "{env.CADDY_OPENTRACING_TEST_TENANT}",
+		Reporter: &ReporterConfig{
+			HTTPHeaders: map[string]string{"X-Scope-OrgID": "{env.CADDY_OPENTRACING_TEST_TENANT}"},
+		},
+	}
+	cfg := c.ToTracingConfig()
+	if err := expandPlaceholders(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServiceName != "tenant-1" {
+		t.Errorf("service name %q, want tenant-1", cfg.ServiceName)
+	}
+	if want := map[string]string{"X-Scope-OrgID": "tenant-1"}; !reflect.DeepEqual(cfg.Reporter.HTTPHeaders, want) {
+		t.Errorf("headers %v, want %v", cfg.Reporter.HTTPHeaders, want)
+	}
+	// the handler config keeps the placeholders, to expand them again on reload
+	if got := c.Reporter.HTTPHeaders["X-Scope-OrgID"]; got != "{env.CADDY_OPENTRACING_TEST_TENANT}" {
+		t.Errorf("raw header changed to %q", got)
+	}
+}
+
+// setenv sets an environment variable for the duration of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
@@ -0,0 +1,103 @@
+package opentracing
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// The protocol tags which can be enabled with Opentracing.ProtocolTags.
+const (
+	protocolVersionTag  = "protocol"
+	tlsVersionTag       = "tls_version"
+	tlsCipherTag        = "tls_cipher"
+	tlsServerNameTag    = "tls_server_name"
+	tlsALPNTag          = "tls_alpn"
+	tlsClientSubjectTag = "tls_client_subject"
+)
+
+// protocolTagKeys maps the protocol tags to the span tag keys.
+var protocolTagKeys = map[string]string{
+	protocolVersionTag:  "net.protocol.version",
+	tlsVersionTag:       "tls.version",
+	tlsCipherTag:        "tls.cipher",
+	tlsServerNameTag:    "tls.server_name",
+	tlsALPNTag:          "tls.alpn",
+	tlsClientSubjectTag: "tls.client.subject",
+}
+
+// allProtocolTags lists every protocol tag, in the order they are set.
+var allProtocolTags = []string{
+	protocolVersionTag,
+	tlsVersionTag,
+	tlsCipherTag,
+	tlsServerNameTag,
+	tlsALPNTag,
+	tlsClientSubjectTag,
+}
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "1.0",
+	tls.VersionTLS11: "1.1",
+	tls.VersionTLS12: "1.2",
+	tls.VersionTLS13: "1.3",
+}
+
+// protocolTags is the set of enabled protocol tags.
+type protocolTags map[string]bool
+
+func newProtocolTags(names []string) (protocolTags, error) {
+	tags := make(protocolTags, len(names))
+	for _, name := range names {
+		if _, ok := protocolTagKeys[name]; !ok {
+			return nil, fmt.Errorf("unknown protocol tag: %s", name)
+		}
+		tags[name] = true
+	}
+	return tags, nil
+}
+
+// set records the HTTP version and the negotiated TLS parameters of r
+// on sp.
+func (tags protocolTags) set(sp opentracing.Span, r *http.Request) {
+	if len(tags) == 0 {
+		return
+	}
+	if tags[protocolVersionTag] {
+		sp.SetTag(protocolTagKeys[protocolVersionTag], protocolVersion(r))
+	}
+	state := r.TLS
+	if state == nil {
+		return
+	}
+	if tags[tlsVersionTag] {
+		version, ok := tlsVersionNames[state.Version]
+		if !ok {
+			version = fmt.Sprintf("0x%04x", state.Version)
+		}
+		sp.SetTag(protocolTagKeys[tlsVersionTag], version)
+	}
+	if tags[tlsCipherTag] {
+		sp.SetTag(protocolTagKeys[tlsCipherTag], tls.CipherSuiteName(state.CipherSuite))
+	}
+	if tags[tlsServerNameTag] && state.ServerName != "" {
+		sp.SetTag(protocolTagKeys[tlsServerNameTag], state.ServerName)
+	}
+	if tags[tlsALPNTag] && state.NegotiatedProtocol != "" {
+		sp.SetTag(protocolTagKeys[tlsALPNTag], state.NegotiatedProtocol)
+	}
+	if tags[tlsClientSubjectTag] && len(state.PeerCertificates) > 0 {
+		sp.SetTag(protocolTagKeys[tlsClientSubjectTag], state.PeerCertificates[0].Subject.String())
+	}
+}
+
+// protocolVersion returns the HTTP version of r, e.g. 1.1, 2 or 3.
+func protocolVersion(r *http.Request) string {
+	if r.ProtoMajor >= 2 && r.ProtoMinor == 0 {
+		return strconv.Itoa(r.ProtoMajor)
+	}
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
@@ -0,0 +1,90 @@
+package opentracing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestProtocolVersion(t *testing.T) {
+	for _, tc := range []struct {
+		major, minor int
+		want         string
+	}{
+		{1, 0, "1.0"},
+		{1, 1, "1.1"},
+		{2, 0, "2"},
+		{3, 0, "3"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.ProtoMajor, r.ProtoMinor = tc.major, tc.minor
+		if got := protocolVersion(r); got != tc.want {
+			t.Errorf("HTTP/%d.%d: version %s, want %s", tc.major, tc.minor, got, tc.want)
+		}
+	}
+}
+
+func TestNewProtocolTags(t *testing.T) {
+	if _, err := newProtocolTags([]string{protocolVersionTag, "tls_nope"}); err == nil {
+		t.Error("unknown protocol tag accepted")
+	}
+}
+
+func TestProtocolTags(t *testing.T) {
+	state := &tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "example.com",
+		NegotiatedProtocol: "h2",
+		PeerCertificates:   []*x509.Certificate{{Subject: pkix.Name{CommonName: "client", Organization: []string{"Acme"}}}},
+	}
+	all := map[string]interface{}{
+		"net.protocol.version": "2",
+		"tls.version":          "1.3",
+		"tls.cipher":           "TLS_AES_128_GCM_SHA256",
+		"tls.server_name":      "example.com",
+		"tls.alpn":             "h2",
+		"tls.client.subject":   "CN=client,O=Acme",
+	}
+
+	for _, tc := range []struct {
+		name  string
+		tags  []string
+		state *tls.ConnectionState
+		want  map[string]interface{}
+	}{
+		{name: "none", state: state, want: map[string]interface{}{}},
+		{name: "all", tags: allProtocolTags, state: state, want: all},
+		{name: "protocol only", tags: []string{protocolVersionTag}, state: state, want: map[string]interface{}{"net.protocol.version": "2"}},
+		{name: "cipher only", tags: []string{tlsCipherTag}, state: state, want: map[string]interface{}{"tls.cipher": "TLS_AES_128_GCM_SHA256"}},
+		{name: "plain text", tags: allProtocolTags, want: map[string]interface{}{"net.protocol.version": "2"}},
+		{
+			name:  "unknown version",
+			tags:  []string{tlsVersionTag, tlsServerNameTag, tlsALPNTag, tlsClientSubjectTag},
+			state: &tls.ConnectionState{Version: 0x0305},
+			want:  map[string]interface{}{"tls.version": "0x0305"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := newProtocolTags(tc.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.ProtoMajor, r.ProtoMinor = 2, 0
+			r.TLS = tc.state
+
+			sp := mocktracer.New().StartSpan("GET /").(*mocktracer.MockSpan)
+			tags.set(sp, r)
+			if got := sp.Tags(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("tags %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package opentracing
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "REDACTED"
+
+// RedactConfig removes sensitive data, such as tokens, e-mail addresses or
+// API keys, from the URL recorded as the http.url tag, from the path in the
+// default operation name and from the tags whose values come from request
+// headers.
+type RedactConfig struct {
+	// QueryParams lists the query parameters whose values are redacted.
+	// Names are compared case-insensitively, after decoding.
+	QueryParams []string `json:"query_params"`
+
+	// PathPatterns lists regular expressions. The path segments matching
+	// one of them are redacted, as well as the matches in header values.
+	PathPatterns []string `json:"path_patterns"`
+
+	// DropQuery removes the query string from the URL entirely.
+	DropQuery bool `json:"drop_query"`
+
+	params   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// provision compiles the patterns.
+func (rc *RedactConfig) provision() error {
+	rc.params = make(map[string]bool, len(rc.QueryParams))
+	for _, param := range rc.QueryParams {
+		rc.params[strings.ToLower(param)] = true
+	}
+	for _, pattern := range rc.PathPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("compiling path pattern %q: %v", pattern, err)
+		}
+		rc.patterns = append(rc.patterns, re)
+	}
+	return nil
+}
+
+// url returns u as a string, redacted.
+func (rc *RedactConfig) url(u *url.URL) string {
+	if rc == nil {
+		return u.String()
+	}
+
+	redacted := *u
+	redacted.User = nil
+	if rc.DropQuery {
+		redacted.RawQuery, redacted.ForceQuery = "", false
+	} else {
+		redacted.RawQuery = rc.query(u.RawQuery)
+	}
+
+	if len(rc.patterns) > 0 {
+		redacted.Path, redacted.RawPath = rc.path(u)
+	}
+	return redacted.String()
+}
+
+// path returns the path of u, both decoded and escaped, with the segments
+// matching the path patterns redacted.
+func (rc *RedactConfig) path(u *url.URL) (path, rawPath string) {
+	if rc == nil || len(rc.patterns) == 0 {
+		return u.Path, u.RawPath
+	}
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			decoded = segment
+		}
+		if segment != "" && rc.matches(decoded) {
+			segments[i] = redactedValue
+		}
+	}
+	rawPath = strings.Join(segments, "/")
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		path = rawPath
+	}
+	return path, rawPath
+}
+
+// query redacts the values of the sensitive parameters of a raw query,
+// leaving the order and encoding of the other parameters untouched.
+func (rc *RedactConfig) query(rawQuery string) string {
+	if rawQuery == "" || len(rc.params) == 0 {
+		return rawQuery
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key := param
+		if j := strings.IndexByte(param, '='); j >= 0 {
+			key = param[:j]
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if rc.params[strings.ToLower(name)] {
+			params[i] = key + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// value redacts a header value, both as a URL when it is one, e.g. the
+// Referer header, and against the path patterns.
+func (rc *RedactConfig) value(v string) string {
+	if rc == nil {
+		return v
+	}
+	if strings.Contains(v, "://") {
+		if u, err := url.Parse(v); err == nil && u.Scheme != "" {
+			v = rc.url(u)
+		}
+	}
+	return rc.text(v)
+}
+
+// text redacts the matches of the path patterns in s.
+func (rc *RedactConfig) text(s string) string {
+	if rc == nil {
+		return s
+	}
+	for _, re := range rc.patterns {
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+func (rc *RedactConfig) matches(s string) bool {
+	for _, re := range rc.patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package opentracing
+
+import (
+	"sync"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// handlers tracks the provisioned handlers of the running configurations,
+// in the order they were provisioned, so that their tracers can be found
+// outside of the handler chain.
+var handlers struct {
+	sync.RWMutex
+	list []*Opentracing
+}
+
+func registerHandler(tracing *Opentracing) {
+	handlers.Lock()
+	handlers.list = append(handlers.list, tracing)
+	handlers.Unlock()
+}
+
+func unregisterHandler(tracing *Opentracing) {
+	handlers.Lock()
+	for i, h := range handlers.list {
+		if h == tracing {
+			handlers.list = append(handlers.list[:i], handlers.list[i+1:]...)
+			break
+		}
+	}
+	handlers.Unlock()
+}
+
+// rangeHandlers calls f for every provisioned handler, in the order they
+// were provisioned.
+func rangeHandlers(f func(tracing *Opentracing)) {
+	handlers.RLock()
+	defer handlers.RUnlock()
+	for _, tracing := range handlers.list {
+		f(tracing)
+	}
+}
+
+// globalMu serializes the changes of the global tracer made by the handlers,
+// and guards globalOwner, the handler whose tracer is the global tracer.
+var (
+	globalMu    sync.Mutex
+	globalOwner *Opentracing
+)
+
+// setGlobalTracer makes the tracer of tracing the global tracer, and
+// remembers the handler it replaces.
+func setGlobalTracer(tracing *Opentracing) {
+	globalMu.Lock()
+	tracing.prevGlobal = globalOwner
+	globalOwner = tracing
+	opentracing.SetGlobalTracer(tracing.tr)
+	globalMu.Unlock()
+}
+
+// resetGlobalTracer replaces the tracer of tracing, which is about to be
+// closed, if it is still the global tracer. During a reload the new
+// configuration is provisioned before the old one is cleaned up, so the
+// tracer of the new configuration is left in place. When the new
+// configuration fails instead, it is cleaned up first and the tracer of the
+// old one, still serving, is restored. The no-op tracer is restored when no
+// previous handler is still provisioned.
+func resetGlobalTracer(tracing *Opentracing) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	prev := tracing.prevGlobal
+	tracing.prevGlobal = nil
+	if globalOwner != tracing {
+		return
+	}
+	for prev != nil && !registeredHandler(prev) {
+		prev = prev.prevGlobal
+	}
+	globalOwner = prev
+	if !opentracing.IsGlobalTracerRegistered() || opentracing.GlobalTracer() != tracing.tr {
+		// replaced by someone else
+		return
+	}
+	if prev != nil {
+		opentracing.SetGlobalTracer(prev.tr)
+	} else {
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	}
+}
+
+// registeredHandler reports whether tracing is provisioned and not cleaned
+// up yet.
+func registeredHandler(tracing *Opentracing) bool {
+	handlers.RLock()
+	defer handlers.RUnlock()
+	for _, h := range handlers.list {
+		if h == tracing {
+			return true
+		}
+	}
+	return false
+}
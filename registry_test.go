@@ -0,0 +1,46 @@
+package opentracing
+
+import (
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+func TestGlobalTracer(t *testing.T) {
+	t.Cleanup(func() { opentracing.SetGlobalTracer(opentracing.NoopTracer{}) })
+
+	start := func() *Opentracing {
+		tracing := &Opentracing{
+			Config:       Config{Sampler: constSampler(true)},
+			EnvOverrides: envIgnore,
+			SetGlobal:    true,
+		}
+		if err := tracing.Provision(newContext(t)); err != nil {
+			t.Fatalf("provisioning: %v", err)
+		}
+		return tracing
+	}
+	assertGlobal := func(want opentracing.Tracer, msg string) {
+		t.Helper()
+		if got := opentracing.GlobalTracer(); got != want {
+			t.Errorf("%s: global tracer = %v, want %v", msg, got, want)
+		}
+	}
+
+	old := start()
+	assertGlobal(old.tr, "provisioned")
+
+	// the new configuration fails, and is cleaned up first
+	failed := start()
+	assertGlobal(failed.tr, "reloading")
+	failed.Cleanup()
+	assertGlobal(old.tr, "failed reload")
+
+	// the new configuration succeeds, and the old one is cleaned up
+	reloaded := start()
+	old.Cleanup()
+	assertGlobal(reloaded.tr, "reloaded")
+
+	reloaded.Cleanup()
+	assertGlobal(opentracing.NoopTracer{}, "stopped")
+}
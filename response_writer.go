@@ -2,21 +2,63 @@ package opentracing
 
 import (
 	"net/http"
+	"sync"
 )
 
+// trackerPool recycles the response writer wrappers of ServeHTTP.
+var trackerPool = sync.Pool{
+	New: func() interface{} { return new(metricsTracker) },
+}
+
+func putTracker(mt *metricsTracker) {
+	*mt = metricsTracker{}
+	trackerPool.Put(mt)
+}
+
 type metricsTracker struct {
 	http.ResponseWriter
 	status int
 	size   int
+
+	// captureHeaders are read when the header is written, into headerValues.
+	captureHeaders []capturedHeader
+	headerValues   []string
+
+	// body records the beginning of the response body, if not nil.
+	body *bodyBuffer
 }
 
 func (w *metricsTracker) WriteHeader(status int) {
-	w.status = status
+	// informational responses, e.g. 103 Early Hints, precede the final one
+	if status >= http.StatusOK {
+		w.status = status
+		w.readHeaders()
+	}
 	w.ResponseWriter.WriteHeader(status)
 }
 
 func (w *metricsTracker) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		// the header is written implicitly
+		w.status = http.StatusOK
+	}
+	w.readHeaders()
 	size, err := w.ResponseWriter.Write(b)
 	w.size += size
+	if w.body != nil {
+		w.body.record(b[:size])
+	}
 	return size, err
 }
+
+// readHeaders reads the values of the captured headers, the first time
+// it is called.
+func (w *metricsTracker) readHeaders() {
+	if len(w.captureHeaders) == 0 || w.headerValues != nil {
+		return
+	}
+	w.headerValues = make([]string, len(w.captureHeaders))
+	for i, h := range w.captureHeaders {
+		w.headerValues[i] = w.Header().Get(h.name)
+	}
+}
@@ -0,0 +1,124 @@
+package opentracing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/config"
+)
+
+// samplerOverride temporarily replaces the sampler of a tracer.
+type samplerOverride struct {
+	Type    string    `json:"type"`
+	Param   float64   `json:"param"`
+	Expires time.Time `json:"expires"`
+
+	sampler jaeger.SamplerV2
+}
+
+// overridableSampler is the sampler given to every tracer. It delegates to
+// the configured sampler, unless an override is set which has not expired
+// yet, so that sampling can be changed at runtime through the admin API.
+type overridableSampler struct {
+	jaeger.SamplerV2Base
+	base jaeger.SamplerV2
+	now  func() time.Time
+
+	mu       sync.RWMutex
+	override *samplerOverride
+}
+
+func newOverridableSampler(base jaeger.Sampler) (*overridableSampler, error) {
+	v2, ok := base.(jaeger.SamplerV2)
+	if !ok {
+		return nil, fmt.Errorf("sampler %T does not implement jaeger.SamplerV2", base)
+	}
+	return &overridableSampler{base: v2, now: time.Now}, nil
+}
+
+// setOverride replaces the sampler with one of the given type until ttl elapsed.
+func (s *overridableSampler) setOverride(samplerType string, param float64, ttl time.Duration) error {
+	switch strings.ToLower(samplerType) {
+	case jaeger.SamplerTypeConst, jaeger.SamplerTypeProbabilistic, jaeger.SamplerTypeRateLimiting:
+	default:
+		return fmt.Errorf("unsupported sampler type for an override (%s)", samplerType)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("the ttl of an override must be positive")
+	}
+	sc := &config.SamplerConfig{Type: samplerType, Param: param}
+	sampler, err := sc.NewSampler("", nil)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.override = &samplerOverride{
+		Type:    samplerType,
+		Param:   param,
+		Expires: s.now().Add(ttl),
+		sampler: sampler.(jaeger.SamplerV2),
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// clearOverride reverts to the configured sampler.
+func (s *overridableSampler) clearOverride() {
+	s.mu.Lock()
+	s.override = nil
+	s.mu.Unlock()
+}
+
+// activeOverride returns the override in effect, if any.
+func (s *overridableSampler) activeOverride() *samplerOverride {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.override == nil || !s.now().Before(s.override.Expires) {
+		return nil
+	}
+	return s.override
+}
+
+func (s *overridableSampler) current() jaeger.SamplerV2 {
+	if o := s.activeOverride(); o != nil {
+		return o.sampler
+	}
+	return s.base
+}
+
+// OnCreateSpan implements jaeger.SamplerV2.
+func (s *overridableSampler) OnCreateSpan(span *jaeger.Span) jaeger.SamplingDecision {
+	return s.current().OnCreateSpan(span)
+}
+
+// OnSetOperationName implements jaeger.SamplerV2.
+func (s *overridableSampler) OnSetOperationName(span *jaeger.Span, operationName string) jaeger.SamplingDecision {
+	return s.current().OnSetOperationName(span, operationName)
+}
+
+// OnSetTag implements jaeger.SamplerV2.
+func (s *overridableSampler) OnSetTag(span *jaeger.Span, key string, value interface{}) jaeger.SamplingDecision {
+	return s.current().OnSetTag(span, key, value)
+}
+
+// OnFinishSpan implements jaeger.SamplerV2.
+func (s *overridableSampler) OnFinishSpan(span *jaeger.Span) jaeger.SamplingDecision {
+	return s.current().OnFinishSpan(span)
+}
+
+// Close implements jaeger.SamplerV2.
+func (s *overridableSampler) Close() {
+	s.base.Close()
+}
+
+// String describes the configured sampler.
+func (s *overridableSampler) String() string {
+	if remote, ok := s.base.(*jaeger.RemotelyControlledSampler); ok {
+		return fmt.Sprintf("RemotelyControlledSampler(%v)", remote.Sampler())
+	}
+	return fmt.Sprint(s.base)
+}
@@ -0,0 +1,66 @@
+package opentracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestOverridableSampler(t *testing.T) {
+	s, err := newOverridableSampler(jaeger.NewConstSampler(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	tr, closer := jaeger.NewTracer("caddy", s, jaeger.NewNullReporter())
+	defer closer.Close()
+	sampled := func() bool {
+		sp := tr.StartSpan("GET /")
+		defer sp.Finish()
+		return sp.Context().(jaeger.SpanContext).IsSampled()
+	}
+
+	if sampled() {
+		t.Error("sampled without an override")
+	}
+	for _, bad := range []struct {
+		samplerType string
+		ttl         time.Duration
+	}{
+		{"remote", time.Minute},
+		{"const", 0},
+		{"const", -time.Minute},
+	} {
+		if err := s.setOverride(bad.samplerType, 1, bad.ttl); err == nil {
+			t.Errorf("override %s for %v accepted", bad.samplerType, bad.ttl)
+		}
+	}
+
+	if err := s.setOverride("const", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !sampled() {
+		t.Error("not sampled with an override")
+	}
+	if o := s.activeOverride(); o == nil || !o.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("override %+v, want one expiring at %v", o, now.Add(time.Minute))
+	}
+
+	now = now.Add(time.Minute)
+	if sampled() {
+		t.Error("sampled after the override expired")
+	}
+	if o := s.activeOverride(); o != nil {
+		t.Errorf("override %+v after its ttl elapsed", o)
+	}
+
+	if err := s.setOverride("probabilistic", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	s.clearOverride()
+	if sampled() {
+		t.Error("sampled after the override was cleared")
+	}
+}
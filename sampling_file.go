@@ -0,0 +1,145 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/thrift-gen/sampling"
+)
+
+const defaultSamplingProbability = 0.001
+
+// strategiesFile is the format of the sampling strategies file of the Jaeger
+// collector. See https://www.jaegertracing.io/docs/latest/sampling/#file-sampling
+type strategiesFile struct {
+	ServiceStrategies []*serviceStrategy `json:"service_strategies"`
+	DefaultStrategy   *serviceStrategy   `json:"default_strategy"`
+}
+
+type strategy struct {
+	Type  string  `json:"type"`
+	Param float64 `json:"param"`
+}
+
+type operationStrategy struct {
+	strategy
+	Operation string `json:"operation"`
+}
+
+type serviceStrategy struct {
+	strategy
+	Service             string               `json:"service"`
+	OperationStrategies []*operationStrategy `json:"operation_strategies"`
+}
+
+// fileStrategySource serves the sampling strategy of a service from a
+// strategies file to the remotely controlled sampler, in place of the
+// sampling server. The file is not watched: the sampler polls it every
+// SamplingRefreshInterval, and it is read again when its modification time
+// changed.
+type fileStrategySource struct {
+	path    string
+	service string
+
+	mu      sync.Mutex
+	modTime time.Time
+	body    []byte
+}
+
+// Fetch implements jaeger.SamplingStrategyFetcher.
+func (s *fileStrategySource) Fetch(string) ([]byte, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.body != nil && info.ModTime().Equal(s.modTime) {
+		return s.body, nil
+	}
+	body, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.body, s.modTime = body, info.ModTime()
+	return body, nil
+}
+
+// Parse implements jaeger.SamplingStrategyParser.
+func (s *fileStrategySource) Parse(body []byte) (interface{}, error) {
+	var file strategiesFile
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, fmt.Errorf("parsing sampling strategies file %s: %v", s.path, err)
+	}
+	return file.strategyFor(s.service)
+}
+
+// strategyFor converts the strategy of service, or the default strategy if
+// the file has none for it, into the response of a sampling server. Like in
+// the Jaeger collector, operation strategies only support probabilistic
+// sampling, and the operations of the default strategy apply to every
+// service unless overridden.
+func (file *strategiesFile) strategyFor(service string) (*sampling.SamplingStrategyResponse, error) {
+	svc := file.DefaultStrategy
+	for _, candidate := range file.ServiceStrategies {
+		if candidate.Service == service {
+			svc = candidate
+			break
+		}
+	}
+	if svc == nil {
+		svc = &serviceStrategy{strategy: strategy{Type: jaeger.SamplerTypeProbabilistic, Param: defaultSamplingProbability}}
+	}
+
+	resp := new(sampling.SamplingStrategyResponse)
+	switch strings.ToLower(svc.Type) {
+	case jaeger.SamplerTypeProbabilistic:
+		resp.StrategyType = sampling.SamplingStrategyType_PROBABILISTIC
+		resp.ProbabilisticSampling = &sampling.ProbabilisticSamplingStrategy{SamplingRate: svc.Param}
+	case jaeger.SamplerTypeRateLimiting:
+		resp.StrategyType = sampling.SamplingStrategyType_RATE_LIMITING
+		resp.RateLimitingSampling = &sampling.RateLimitingSamplingStrategy{MaxTracesPerSecond: int16(svc.Param)}
+	default:
+		return nil, fmt.Errorf("unknown strategy type (%s) for service %s", svc.Type, service)
+	}
+
+	operations := make(map[string]bool)
+	var perOperation []*sampling.OperationSamplingStrategy
+	addOperations := func(strategies []*operationStrategy) {
+		for _, op := range strategies {
+			if operations[op.Operation] || !strings.EqualFold(op.Type, jaeger.SamplerTypeProbabilistic) {
+				continue
+			}
+			operations[op.Operation] = true
+			perOperation = append(perOperation, &sampling.OperationSamplingStrategy{
+				Operation:             op.Operation,
+				ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: op.Param},
+			})
+		}
+	}
+	addOperations(svc.OperationStrategies)
+	if file.DefaultStrategy != nil && svc != file.DefaultStrategy {
+		addOperations(file.DefaultStrategy.OperationStrategies)
+	}
+	if len(perOperation) == 0 {
+		return resp, nil
+	}
+
+	resp.OperationSampling = &sampling.PerOperationSamplingStrategies{
+		DefaultSamplingProbability: defaultSamplingProbability,
+		PerOperationStrategies:     perOperation,
+	}
+	if resp.ProbabilisticSampling != nil {
+		resp.OperationSampling.DefaultSamplingProbability = resp.ProbabilisticSampling.SamplingRate
+	} else {
+		// the per operation sampler guarantees the rate of the service to every operation
+		resp.OperationSampling.DefaultLowerBoundTracesPerSecond = svc.Param
+	}
+	return resp, nil
+}
@@ -0,0 +1,169 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uber/jaeger-client-go/thrift-gen/sampling"
+)
+
+const testStrategies = `{
+	"service_strategies": [
+		{
+			"service": "probabilistic",
+			"type": "probabilistic",
+			"param": 0.5,
+			"operation_strategies": [
+				{"operation": "GET /", "type": "probabilistic", "param": 1},
+				{"operation": "GET /health", "type": "ratelimiting", "param": 1}
+			]
+		},
+		{"service": "ratelimiting", "type": "ratelimiting", "param": 5}
+	],
+	"default_strategy": {
+		"type": "probabilistic",
+		"param": 0.1,
+		"operation_strategies": [
+			{"operation": "GET /", "type": "probabilistic", "param": 0},
+			{"operation": "GET /metrics", "type": "probabilistic", "param": 0}
+		]
+	}
+}`
+
+func TestStrategyFor(t *testing.T) {
+	var file strategiesFile
+	if err := json.Unmarshal([]byte(testStrategies), &file); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		service string
+		want    *sampling.SamplingStrategyResponse
+	}{
+		{
+			// its own operations win over the default ones, the ratelimiting one is ignored
+			service: "probabilistic",
+			want: &sampling.SamplingStrategyResponse{
+				StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+				ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0.5},
+				OperationSampling: &sampling.PerOperationSamplingStrategies{
+					DefaultSamplingProbability: 0.5,
+					PerOperationStrategies: []*sampling.OperationSamplingStrategy{
+						{Operation: "GET /", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 1}},
+						{Operation: "GET /metrics", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0}},
+					},
+				},
+			},
+		},
+		{
+			// the rate of the service is the lower bound of every operation
+			service: "ratelimiting",
+			want: &sampling.SamplingStrategyResponse{
+				StrategyType:         sampling.SamplingStrategyType_RATE_LIMITING,
+				RateLimitingSampling: &sampling.RateLimitingSamplingStrategy{MaxTracesPerSecond: 5},
+				OperationSampling: &sampling.PerOperationSamplingStrategies{
+					DefaultSamplingProbability:       defaultSamplingProbability,
+					DefaultLowerBoundTracesPerSecond: 5,
+					PerOperationStrategies: []*sampling.OperationSamplingStrategy{
+						{Operation: "GET /", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0}},
+						{Operation: "GET /metrics", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0}},
+					},
+				},
+			},
+		},
+		{
+			service: "other",
+			want: &sampling.SamplingStrategyResponse{
+				StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+				ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0.1},
+				OperationSampling: &sampling.PerOperationSamplingStrategies{
+					DefaultSamplingProbability: 0.1,
+					PerOperationStrategies: []*sampling.OperationSamplingStrategy{
+						{Operation: "GET /", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0}},
+						{Operation: "GET /metrics", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0}},
+					},
+				},
+			},
+		},
+	} {
+		got, err := file.strategyFor(tc.service)
+		if err != nil {
+			t.Errorf("%s: %v", tc.service, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.service, got, tc.want)
+		}
+	}
+}
+
+func TestStrategyForEmpty(t *testing.T) {
+	got, err := new(strategiesFile).strategyFor("any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &sampling.SamplingStrategyResponse{
+		StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+		ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: defaultSamplingProbability},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	file := &strategiesFile{DefaultStrategy: &serviceStrategy{strategy: strategy{Type: "const"}}}
+	if _, err := file.strategyFor("any"); err == nil {
+		t.Error("unknown strategy type accepted")
+	}
+}
+
+func TestFileStrategySourceFetch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strategies.json")
+	if err := os.WriteFile(path, []byte(`{"default_strategy": {"type": "probabilistic", "param": 0.1}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &fileStrategySource{path: path, service: "caddy"}
+	probability := func() float64 {
+		t.Helper()
+		body, err := s.Fetch("caddy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := s.Parse(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.(*sampling.SamplingStrategyResponse).ProbabilisticSampling.SamplingRate
+	}
+	if p := probability(); p != 0.1 {
+		t.Errorf("probability %v, want 0.1", p)
+	}
+
+	// the file is not read again until its modification time changes
+	if err := os.WriteFile(path, []byte(`{"default_strategy": {"type": "probabilistic", "param": 0.2}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, s.modTime, s.modTime); err != nil {
+		t.Fatal(err)
+	}
+	if p := probability(); p != 0.1 {
+		t.Errorf("probability %v before the modification time changed, want 0.1", p)
+	}
+	modTime := s.modTime.Add(time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if p := probability(); p != 0.2 {
+		t.Errorf("probability %v after the modification time changed, want 0.2", p)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Fetch("caddy"); err == nil {
+		t.Error("fetching a removed file succeeded")
+	}
+}
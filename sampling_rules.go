@@ -0,0 +1,73 @@
+package opentracing
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/utils"
+)
+
+// SamplingRule decides locally whether the requests it matches start a
+// sampled trace. The rules are evaluated in order before the request span
+// is created, and the first matching rule wins. Requests which carry an
+// incoming trace keep the decision of their parent; baggage or a debug ID
+// alone do not count.
+type SamplingRule struct {
+	// MatchersRaw restricts the rule to the requests matching one of the
+	// matcher sets. A rule without matchers matches every request.
+	MatchersRaw caddyhttp.RawMatcherSets `json:"match" caddy:"namespace=http.matchers"`
+
+	// Type specifies the type of the sampler: const, probabilistic or ratelimiting.
+	Type string `json:"type"`
+
+	// Param is a value passed to the sampler.
+	// - for "const" sampler, 0 or 1 for always false/true respectively
+	// - for "probabilistic" sampler, a probability between 0 and 1
+	// - for "ratelimiting" sampler, the number of spans per second
+	Param float64 `json:"param"`
+
+	matchers caddyhttp.MatcherSets
+	sample   func() bool
+}
+
+// provision loads the matchers of the rule and sets up its sampler.
+func (rule *SamplingRule) provision(ctx caddy.Context) error {
+	mods, err := ctx.LoadModule(rule, "MatchersRaw")
+	if err != nil {
+		return fmt.Errorf("loading matchers: %v", err)
+	}
+	if err = rule.matchers.FromInterface(mods); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(rule.Type) {
+	case jaeger.SamplerTypeConst:
+		sampled := rule.Param != 0
+		rule.sample = func() bool { return sampled }
+	case jaeger.SamplerTypeProbabilistic:
+		if rule.Param < 0 || rule.Param > 1 {
+			return fmt.Errorf("invalid param for probabilistic sampling rule; expecting value between 0 and 1, received %v", rule.Param)
+		}
+		rate := rule.Param
+		rule.sample = func() bool { return rand.Float64() < rate }
+	case jaeger.SamplerTypeRateLimiting:
+		if rule.Param < 0 {
+			return fmt.Errorf("invalid param for ratelimiting sampling rule; expecting a positive value, received %v", rule.Param)
+		}
+		limiter := utils.NewRateLimiter(rule.Param, math.Max(rule.Param, 1.0))
+		rule.sample = func() bool { return limiter.CheckCredit(1.0) }
+	default:
+		return fmt.Errorf("unknown sampling rule type (%s)", rule.Type)
+	}
+	return nil
+}
+
+func (rule *SamplingRule) match(r *http.Request) bool {
+	return len(rule.matchers) == 0 || rule.matchers.AnyMatch(r)
+}
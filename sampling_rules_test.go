@@ -0,0 +1,116 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestSamplingRuleProvision(t *testing.T) {
+	for _, tc := range []struct {
+		rule    SamplingRule
+		wantErr bool
+	}{
+		{rule: SamplingRule{Type: "const", Param: 1}},
+		{rule: SamplingRule{Type: "Const", Param: 0}},
+		{rule: SamplingRule{Type: "probabilistic", Param: 0.5}},
+		{rule: SamplingRule{Type: "probabilistic", Param: 1.5}, wantErr: true},
+		{rule: SamplingRule{Type: "probabilistic", Param: -0.1}, wantErr: true},
+		{rule: SamplingRule{Type: "ratelimiting", Param: 10}},
+		{rule: SamplingRule{Type: "ratelimiting", Param: -1}, wantErr: true},
+		{rule: SamplingRule{Type: "remote", Param: 1}, wantErr: true},
+		{rule: SamplingRule{Type: "", Param: 1}, wantErr: true},
+	} {
+		rule := tc.rule
+		if err := rule.provision(newContext(t)); (err != nil) != tc.wantErr {
+			t.Errorf("provision(%s %v) error = %v, want error %v", tc.rule.Type, tc.rule.Param, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSamplingRuleSample(t *testing.T) {
+	sample := func(typ string, param float64, n int) (sampled int) {
+		rule := &SamplingRule{Type: typ, Param: param}
+		if err := rule.provision(newContext(t)); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if rule.sample() {
+				sampled++
+			}
+		}
+		return sampled
+	}
+	for _, tc := range []struct {
+		typ   string
+		param float64
+		want  int
+	}{
+		{typ: "const", param: 1, want: 100},
+		{typ: "const", param: 0, want: 0},
+		{typ: "probabilistic", param: 1, want: 100},
+		{typ: "probabilistic", param: 0, want: 0},
+		// the limiter starts with a full balance, and barely refills
+		// during the test
+		{typ: "ratelimiting", param: 3, want: 3},
+		{typ: "ratelimiting", param: 0.01, want: 1},
+	} {
+		if got := sample(tc.typ, tc.param, 100); got != tc.want {
+			t.Errorf("%s %v sampled %d of 100, want %d", tc.typ, tc.param, got, tc.want)
+		}
+	}
+}
+
+func TestSamplingRules(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	defer SetTestReporter(reporter)()
+	tracing := provision(t, &Opentracing{
+		Config:       Config{Sampler: constSampler(true)},
+		EnvOverrides: envIgnore,
+		SamplingRules: []*SamplingRule{
+			{
+				Type:        "const",
+				Param:       1,
+				MatchersRaw: caddyhttp.RawMatcherSets{{"path": json.RawMessage(`["/static/keep"]`)}},
+			},
+			{
+				Type:        "const",
+				Param:       0,
+				MatchersRaw: caddyhttp.RawMatcherSets{{"path": json.RawMessage(`["/static/*"]`)}},
+			},
+		},
+	})
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		header  string
+		value   string
+		sampled bool
+	}{
+		{name: "no rule", path: "/", sampled: true},
+		{name: "first match", path: "/static/keep", sampled: true},
+		{name: "second match", path: "/static/app.js", sampled: false},
+		// baggage or a debug ID alone do not bypass the rules
+		{name: "baggage", path: "/static/app.js", header: "Jaeger-Baggage", value: "a=b", sampled: false},
+		{name: "baggage item", path: "/static/app.js", header: "Uberctx-X", value: "y", sampled: false},
+		// a sampled parent wins over the rules
+		{name: "parent", path: "/static/app.js", header: "Uber-Trace-Id", value: "1f:2f:0:1", sampled: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter.Reset()
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				r.Header.Set(tc.header, tc.value)
+			}
+			serve(t, tracing, r, nopHandler)
+			if got := reporter.SpansSubmitted() == 1; got != tc.sampled {
+				t.Errorf("sampled = %v, want %v", got, tc.sampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package opentracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// lbPolicyPlaceholder is set by TracedSelection to the name of the policy
+// which selected the upstream of the current attempt.
+const lbPolicyPlaceholder = "http.opentracing.upstream.lb_policy"
+
+func init() {
+	caddy.RegisterModule(TracedSelection{})
+}
+
+// TracedSelection wraps a load balancing selection policy of reverse_proxy,
+// so that the upstream spans record the name of the policy. The handler
+// cannot read the config of reverse_proxy, which the policy comes from.
+//
+//	reverse_proxy a:80 b:80 {
+//		lb_policy traced round_robin
+//	}
+type TracedSelection struct {
+	// SelectionRaw is the wrapped policy.
+	SelectionRaw json.RawMessage `json:"selection" caddy:"namespace=http.reverse_proxy.selection_policies inline_key=policy"`
+
+	selection reverseproxy.Selector
+	name      string
+}
+
+// CaddyModule returns the Caddy module information.
+func (TracedSelection) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.reverse_proxy.selection_policies.traced",
+		New: func() caddy.Module { return new(TracedSelection) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (s *TracedSelection) Provision(ctx caddy.Context) error {
+	if s.SelectionRaw == nil {
+		s.SelectionRaw = caddyconfig.JSONModuleObject(reverseproxy.RandomSelection{}, "policy", "random", nil)
+	}
+	mod, err := ctx.LoadModule(s, "SelectionRaw")
+	if err != nil {
+		return fmt.Errorf("loading selection policy: %v", err)
+	}
+	selection, ok := mod.(reverseproxy.Selector)
+	if !ok {
+		return fmt.Errorf("module %T is not a reverseproxy.Selector", mod)
+	}
+	s.selection = selection
+	s.name = caddy.GetModuleName(mod)
+	return nil
+}
+
+// Select implements reverseproxy.Selector.
+func (s *TracedSelection) Select(pool reverseproxy.UpstreamPool, r *http.Request, w http.ResponseWriter) *reverseproxy.Upstream {
+	if repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+		repl.Set(lbPolicyPlaceholder, s.name)
+	}
+	return s.selection.Select(pool, r, w)
+}
+
+// UnmarshalCaddyfile sets up the wrapped policy from Caddyfile tokens:
+//
+//	traced [<policy> [<options...>]]
+func (s *TracedSelection) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		if !d.NextArg() {
+			continue
+		}
+		name := d.Val()
+		unm, err := caddyfile.UnmarshalModule(d, "http.reverse_proxy.selection_policies."+name)
+		if err != nil {
+			return err
+		}
+		s.SelectionRaw = caddyconfig.JSONModuleObject(unm, "policy", name, nil)
+	}
+	return nil
+}
+
+// Interface guards
+var (
+	_ reverseproxy.Selector = (*TracedSelection)(nil)
+	_ caddy.Provisioner     = (*TracedSelection)(nil)
+	_ caddyfile.Unmarshaler = (*TracedSelection)(nil)
+)
@@ -0,0 +1,158 @@
+package opentracing
+
+import (
+	"io"
+	"sync"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxSiteTracers = 16
+	siteServiceKey        = "site.service"
+)
+
+// siteTracer is a tracer with what it needs at request time.
+type siteTracer struct {
+	tr      opentracing.Tracer
+	closer  io.Closer
+	tail    *tailSampler
+	sampler *overridableSampler
+}
+
+// siteTracers creates the tracers of the site services on demand, up to max,
+// and only for the allowed services when allowed is not empty.
+type siteTracers struct {
+	create  func(serviceName string) (siteTracer, error)
+	max     int
+	allowed map[string]bool
+	logger  *zap.Logger
+
+	mu      sync.RWMutex
+	tracers map[string]siteTracer
+	pending map[string]*pendingTracer
+	closed  bool
+}
+
+// pendingTracer is a tracer being created, done is closed once it is.
+type pendingTracer struct {
+	done   chan struct{}
+	tracer siteTracer
+	ok     bool
+}
+
+func newSiteTracers(max int, allowed []string, logger *zap.Logger, create func(serviceName string) (siteTracer, error)) *siteTracers {
+	st := &siteTracers{
+		create:  create,
+		max:     max,
+		logger:  logger,
+		tracers: make(map[string]siteTracer),
+		pending: make(map[string]*pendingTracer),
+	}
+	if len(allowed) > 0 {
+		st.allowed = make(map[string]bool, len(allowed))
+		for _, serviceName := range allowed {
+			st.allowed[serviceName] = true
+		}
+	}
+	return st
+}
+
+// get returns the tracer of the service, creating it if it is allowed and
+// there is room. The tracer is created without holding the lock, since it
+// may take a while, e.g. to resolve the agent address; the concurrent
+// requests for the same service wait for it instead of creating their own.
+func (st *siteTracers) get(serviceName string) (siteTracer, bool) {
+	if st.allowed != nil && !st.allowed[serviceName] {
+		return siteTracer{}, false
+	}
+	st.mu.RLock()
+	t, ok := st.tracers[serviceName]
+	_, creating := st.pending[serviceName]
+	full := st.closed || len(st.tracers)+len(st.pending) >= st.max
+	st.mu.RUnlock()
+	if ok || (full && !creating) {
+		return t, ok
+	}
+
+	st.mu.Lock()
+	if t, ok := st.tracers[serviceName]; ok {
+		st.mu.Unlock()
+		return t, true
+	}
+	if p, ok := st.pending[serviceName]; ok {
+		st.mu.Unlock()
+		<-p.done
+		return p.tracer, p.ok
+	}
+	if st.closed || len(st.tracers)+len(st.pending) >= st.max {
+		st.mu.Unlock()
+		return siteTracer{}, false
+	}
+	p := &pendingTracer{done: make(chan struct{})}
+	st.pending[serviceName] = p
+	st.mu.Unlock()
+
+	t, err := st.create(serviceName)
+
+	st.mu.Lock()
+	delete(st.pending, serviceName)
+	if err == nil && !st.closed {
+		st.tracers[serviceName] = t
+		p.tracer, p.ok = t, true
+	}
+	st.mu.Unlock()
+	close(p.done)
+
+	if err != nil {
+		st.logger.Error("creating site tracer", zap.String("service_name", serviceName), zap.Error(err))
+	} else if !p.ok {
+		// the tracers were closed while it was created
+		st.closeTracer(serviceName, t)
+	}
+	return p.tracer, p.ok
+}
+
+// lookup returns the tracer of the service if it was created.
+func (st *siteTracers) lookup(serviceName string) (siteTracer, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	t, ok := st.tracers[serviceName]
+	return t, ok
+}
+
+// each calls f with every tracer created.
+func (st *siteTracers) each(f func(serviceName string, t siteTracer)) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	for serviceName, t := range st.tracers {
+		f(serviceName, t)
+	}
+}
+
+// Close closes the tracers.
+func (st *siteTracers) Close() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.closed = true
+	for serviceName, t := range st.tracers {
+		st.closeTracer(serviceName, t)
+		delete(st.tracers, serviceName)
+	}
+}
+
+func (st *siteTracers) closeTracer(serviceName string, t siteTracer) {
+	if err := t.closer.Close(); err != nil {
+		st.logger.Error("closing site tracer", zap.String("service_name", serviceName), zap.Error(err))
+	}
+}
+
+// sharedSampler is the sampler of the handler as the base of the samplers
+// of the site tracers, which must not close it when they are closed. A site
+// follows the overrides of the handler unless it is overridden itself.
+type sharedSampler struct {
+	*overridableSampler
+}
+
+func (sharedSampler) Close() {}
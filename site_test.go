@@ -0,0 +1,185 @@
+package opentracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+)
+
+// countingCloser counts the tracers closed.
+type countingCloser struct{ closed *int32 }
+
+func (c countingCloser) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return nil
+}
+
+func newTestSiteTracers(max int, allowed []string) (st *siteTracers, created, closed *int32) {
+	created, closed = new(int32), new(int32)
+	st = newSiteTracers(max, allowed, zap.NewNop(), func(serviceName string) (siteTracer, error) {
+		atomic.AddInt32(created, 1)
+		return siteTracer{tr: opentracing.NoopTracer{}, closer: countingCloser{closed}}, nil
+	})
+	return st, created, closed
+}
+
+func TestSiteTracersMax(t *testing.T) {
+	st, created, closed := newTestSiteTracers(2, nil)
+	for _, name := range []string{"a", "b", "a"} {
+		if _, ok := st.get(name); !ok {
+			t.Errorf("no tracer for %s", name)
+		}
+	}
+	if _, ok := st.get("c"); ok {
+		t.Error("tracer created beyond the maximum")
+	}
+	if *created != 2 {
+		t.Errorf("%d tracers created, want 2", *created)
+	}
+
+	st.Close()
+	if *closed != 2 {
+		t.Errorf("%d tracers closed, want 2", *closed)
+	}
+	if _, ok := st.get("d"); ok {
+		t.Error("tracer created after Close")
+	}
+}
+
+func TestSiteTracersAllowed(t *testing.T) {
+	st, created, _ := newTestSiteTracers(2, []string{"example.com"})
+	for _, name := range []string{"junk1", "junk2", "junk3"} {
+		if _, ok := st.get(name); ok {
+			t.Errorf("tracer created for %s", name)
+		}
+	}
+	if _, ok := st.get("example.com"); !ok {
+		t.Error("no tracer for example.com")
+	}
+	if *created != 1 {
+		t.Errorf("%d tracers created, want 1", *created)
+	}
+}
+
+func TestSiteTracersConcurrent(t *testing.T) {
+	st, created, closed := newTestSiteTracers(1, nil)
+	var wg sync.WaitGroup
+	got := make([]siteTracer, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], _ = st.get("a")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range got {
+		if got[i] != got[0] {
+			t.Errorf("request %d got another tracer", i)
+		}
+	}
+	// the requests wait for the tracer created by the first one
+	if *created != 1 || *closed != 0 {
+		t.Errorf("%d tracers created and %d closed, want 1 and 0", *created, *closed)
+	}
+}
+
+func TestSiteTracersPending(t *testing.T) {
+	release := make(chan struct{})
+	st := newSiteTracers(1, nil, zap.NewNop(), func(serviceName string) (siteTracer, error) {
+		<-release
+		return siteTracer{tr: opentracing.NoopTracer{}, closer: countingCloser{new(int32)}}, nil
+	})
+	done := make(chan bool)
+	go func() {
+		_, ok := st.get("a")
+		done <- ok
+	}()
+	for {
+		st.mu.RLock()
+		n := len(st.pending)
+		st.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	// the tracer being created takes the only room
+	if _, ok := st.get("b"); ok {
+		t.Error("tracer created beyond the maximum")
+	}
+	close(release)
+	if !<-done {
+		t.Error("no tracer for a")
+	}
+}
+
+func TestSiteTracersCreateUnlocked(t *testing.T) {
+	var st *siteTracers
+	st = newSiteTracers(2, nil, zap.NewNop(), func(serviceName string) (siteTracer, error) {
+		// would deadlock if the write lock were held
+		st.lookup(serviceName)
+		return siteTracer{tr: opentracing.NoopTracer{}, closer: countingCloser{new(int32)}}, nil
+	})
+	if _, ok := st.get("a"); !ok {
+		t.Error("no tracer for a")
+	}
+}
+
+func provisionSites(t *testing.T) *Opentracing {
+	t.Helper()
+	defer SetTestReporter(jaeger.NewInMemoryReporter())()
+	return provision(t, &Opentracing{
+		Config:          Config{ServiceName: "caddy", Sampler: constSampler(false)},
+		EnvOverrides:    envIgnore,
+		SiteServiceName: "example.com",
+	})
+}
+
+func TestSiteTracersFiltered(t *testing.T) {
+	tracing := provisionSites(t)
+	tracing.opts.spanFilter = func(r *http.Request) bool { return false }
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), nopHandler)
+	if _, ok := tracing.sites.lookup("example.com"); ok {
+		t.Error("tracer created for a request which is not traced")
+	}
+}
+
+func TestSiteTracersOverride(t *testing.T) {
+	tracing := provisionSites(t)
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), nopHandler)
+
+	var as adminSampling
+	w := httptest.NewRecorder()
+	body := `{"service_name": "example.com", "type": "const", "param": 1, "ttl": "10m"}`
+	if err := as.handleSampling(w, httptest.NewRequest(http.MethodPost, "/opentracing/sampling", strings.NewReader(body))); err != nil {
+		t.Fatalf("overriding the site: %v", err)
+	}
+	site, _ := tracing.sites.lookup("example.com")
+	if site.sampler.activeOverride() == nil {
+		t.Error("site sampler not overridden")
+	}
+	if tracing.sampler.activeOverride() != nil {
+		t.Error("handler sampler overridden")
+	}
+
+	w = httptest.NewRecorder()
+	if err := as.handleSampling(w, httptest.NewRequest(http.MethodGet, "/opentracing/sampling", nil)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"service_name":"caddy"`, `"service_name":"example.com"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("listing %s does not contain %s", w.Body, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package opentracing
+
+import (
+	"container/list"
+	"math/rand"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	defaultTailMaxTraces        = 10000
+	defaultTailMaxSpansPerTrace = 1000
+	defaultTailDecisionWait     = 30 * time.Second
+)
+
+// TailSamplingConfig configures tail-based sampling. Every span is recorded,
+// and the spans of a trace are buffered in memory until the request that
+// started them finishes. The trace is then reported only if one of the rules
+// matches, or else with the given probability.
+type TailSamplingConfig struct {
+	// MinStatus keeps traces whose request ended with a status code
+	// greater than or equal to this value. Zero disables the rule.
+	MinStatus int `json:"min_status"`
+
+	// MinDuration keeps traces whose request took at least this long.
+	// Zero disables the rule.
+	MinDuration caddy.Duration `json:"min_duration"`
+
+	// Paths keeps traces whose request path matches one of these patterns.
+	// See https://pkg.go.dev/path#Match for the pattern syntax.
+	Paths []string `json:"paths"`
+
+	// Probability is the probability between 0 and 1 of keeping a trace
+	// that matches none of the rules.
+	Probability float64 `json:"probability"`
+
+	// MaxTraces bounds the number of traces buffered at once. When it is
+	// exceeded, the traces already decided are evicted first, then the
+	// oldest undecided trace. Default 10000.
+	MaxTraces int `json:"max_traces"`
+
+	// MaxSpansPerTrace bounds the number of spans buffered for one trace.
+	// Spans beyond it are dropped. Default 1000.
+	MaxSpansPerTrace int `json:"max_spans_per_trace"`
+
+	// DecisionWait is how long a trace is kept in memory waiting for its
+	// decision, then how long the decision is kept for the spans finishing
+	// after it. Default 30s.
+	DecisionWait caddy.Duration `json:"decision_wait"`
+}
+
+// tailSampler is a jaeger.Reporter which buffers the finished spans of every
+// trace until decide is called for it, then forwards them to reporter or
+// drops them.
+//
+// Undecided traces are kept in pending, oldest first. Decided traces only
+// remember the decision for the spans finishing late, in decided, and are
+// evicted before any undecided trace when MaxTraces is reached.
+type tailSampler struct {
+	cfg      TailSamplingConfig
+	reporter jaeger.Reporter
+	now      func() time.Time
+	random   func() float64
+
+	mu      sync.Mutex
+	traces  map[jaeger.TraceID]*list.Element
+	pending *list.List
+	decided *list.List
+}
+
+type tailTrace struct {
+	id      jaeger.TraceID
+	expires time.Time
+	spans   []*jaeger.Span
+	decided bool
+	keep    bool
+}
+
+func newTailSampler(cfg TailSamplingConfig, reporter jaeger.Reporter) *tailSampler {
+	if cfg.MaxTraces <= 0 {
+		cfg.MaxTraces = defaultTailMaxTraces
+	}
+	if cfg.MaxSpansPerTrace <= 0 {
+		cfg.MaxSpansPerTrace = defaultTailMaxSpansPerTrace
+	}
+	if cfg.DecisionWait <= 0 {
+		cfg.DecisionWait = caddy.Duration(defaultTailDecisionWait)
+	}
+	tracingMetrics.init.Do(initTracingMetrics)
+	return &tailSampler{
+		cfg:      cfg,
+		reporter: reporter,
+		now:      time.Now,
+		random:   rand.Float64,
+		traces:   make(map[jaeger.TraceID]*list.Element),
+		pending:  list.New(),
+		decided:  list.New(),
+	}
+}
+
+// keep evaluates the rules for a finished request.
+func (ts *tailSampler) keep(r *http.Request, status int, duration time.Duration) bool {
+	if ts.cfg.MinStatus > 0 && status >= ts.cfg.MinStatus {
+		return true
+	}
+	if ts.cfg.MinDuration > 0 && duration >= time.Duration(ts.cfg.MinDuration) {
+		return true
+	}
+	for _, pattern := range ts.cfg.Paths {
+		if ok, _ := path.Match(pattern, r.URL.Path); ok {
+			return true
+		}
+	}
+	return ts.random() < ts.cfg.Probability
+}
+
+// Report implements jaeger.Reporter.
+func (ts *tailSampler) Report(span *jaeger.Span) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	t := ts.elementLocked(span.SpanContext().TraceID()).Value.(*tailTrace)
+	if t.decided {
+		if t.keep {
+			ts.reporter.Report(span)
+		}
+		return
+	}
+	if len(t.spans) >= ts.cfg.MaxSpansPerTrace {
+		tracingMetrics.tailDroppedSpans.Inc()
+		return
+	}
+	t.spans = append(t.spans, span.Retain())
+}
+
+// decide reports or drops the buffered spans of the trace, as well as the
+// spans of the trace which finish later on.
+func (ts *tailSampler) decide(id jaeger.TraceID, keep bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	e := ts.elementLocked(id)
+	t := e.Value.(*tailTrace)
+	if t.decided {
+		return
+	}
+	t.decided, t.keep = true, keep
+	t.expires = ts.now().Add(time.Duration(ts.cfg.DecisionWait))
+	ts.pending.Remove(e)
+	ts.traces[id] = ts.decided.PushBack(t)
+	for _, span := range t.spans {
+		if keep {
+			ts.reporter.Report(span)
+		}
+		span.Release()
+	}
+	t.spans = nil
+
+	if keep {
+		tracingMetrics.tailTraces.WithLabelValues("kept").Inc()
+	} else {
+		tracingMetrics.tailTraces.WithLabelValues("dropped").Inc()
+	}
+}
+
+// elementLocked returns the element of the trace, creating an undecided
+// trace if needed. The expired traces are evicted first, then the decided
+// traces and the oldest undecided ones while MaxTraces is exceeded.
+func (ts *tailSampler) elementLocked(id jaeger.TraceID) *list.Element {
+	now := ts.now()
+	ts.expireLocked(ts.pending, now)
+	ts.expireLocked(ts.decided, now)
+
+	if e, ok := ts.traces[id]; ok {
+		return e
+	}
+	for len(ts.traces) >= ts.cfg.MaxTraces {
+		if e := ts.decided.Front(); e != nil {
+			ts.evictLocked(ts.decided, e)
+		} else {
+			ts.evictLocked(ts.pending, ts.pending.Front())
+		}
+	}
+	e := ts.pending.PushBack(&tailTrace{
+		id:      id,
+		expires: now.Add(time.Duration(ts.cfg.DecisionWait)),
+	})
+	ts.traces[id] = e
+	tracingMetrics.tailBuffered.Inc()
+	return e
+}
+
+// expireLocked evicts the traces of l which expired by now. The traces of a
+// list expire in order.
+func (ts *tailSampler) expireLocked(l *list.List, now time.Time) {
+	for e := l.Front(); e != nil; e = l.Front() {
+		if now.Before(e.Value.(*tailTrace).expires) {
+			return
+		}
+		ts.evictLocked(l, e)
+	}
+}
+
+func (ts *tailSampler) evictLocked(l *list.List, e *list.Element) {
+	t := l.Remove(e).(*tailTrace)
+	delete(ts.traces, t.id)
+	tracingMetrics.tailBuffered.Dec()
+	if t.decided {
+		return
+	}
+	for _, span := range t.spans {
+		span.Release()
+	}
+	tracingMetrics.tailTraces.WithLabelValues("evicted").Inc()
+}
+
+// Close implements jaeger.Reporter. Undecided traces are dropped.
+func (ts *tailSampler) Close() {
+	ts.mu.Lock()
+	for _, l := range []*list.List{ts.pending, ts.decided} {
+		for e := l.Front(); e != nil; e = l.Front() {
+			ts.evictLocked(l, e)
+		}
+	}
+	ts.mu.Unlock()
+	ts.reporter.Close()
+}
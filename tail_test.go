@@ -0,0 +1,187 @@
+package opentracing
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/uber/jaeger-client-go"
+)
+
+// newTestTailSampler returns a tail sampler with a fake clock, reporting to
+// the returned reporter, and a tracer whose spans it buffers.
+func newTestTailSampler(t *testing.T, cfg TailSamplingConfig) (*tailSampler, *jaeger.InMemoryReporter, *time.Time, *jaeger.Tracer) {
+	t.Helper()
+	reporter := jaeger.NewInMemoryReporter()
+	ts := newTailSampler(cfg, reporter)
+	now := time.Unix(0, 0)
+	ts.now = func() time.Time { return now }
+	ts.random = func() float64 { return 0.5 }
+	tr, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), ts)
+	t.Cleanup(func() { closer.Close() })
+	return ts, reporter, &now, tr.(*jaeger.Tracer)
+}
+
+// finishTrace finishes a root span and returns the ID of its trace.
+func finishTrace(tr *jaeger.Tracer) jaeger.TraceID {
+	sp := tr.StartSpan("root")
+	sp.Finish()
+	return sp.Context().(jaeger.SpanContext).TraceID()
+}
+
+// finishChild finishes a child span in the trace of id.
+func finishChild(tr *jaeger.Tracer, id jaeger.TraceID) {
+	parent := jaeger.NewSpanContext(id, jaeger.SpanID(1), 0, true, nil)
+	tr.StartSpan("child", jaeger.SelfRef(parent)).Finish()
+}
+
+func TestTailSamplerKeep(t *testing.T) {
+	ts, _, _, _ := newTestTailSampler(t, TailSamplingConfig{
+		MinStatus:   500,
+		MinDuration: caddy.Duration(time.Second),
+		Paths:       []string{"/api/*"},
+		Probability: 0.25,
+	})
+	for _, tc := range []struct {
+		path     string
+		status   int
+		duration time.Duration
+		want     bool
+	}{
+		{path: "/", status: 200, want: false},
+		{path: "/", status: 503, want: true},
+		{path: "/", status: 200, duration: 2 * time.Second, want: true},
+		{path: "/api/users", status: 200, want: true},
+	} {
+		r := httptest.NewRequest("GET", tc.path, nil)
+		if got := ts.keep(r, tc.status, tc.duration); got != tc.want {
+			t.Errorf("keep(%s, %d, %s) = %v, want %v", tc.path, tc.status, tc.duration, got, tc.want)
+		}
+	}
+	ts.random = func() float64 { return 0.1 }
+	if !ts.keep(httptest.NewRequest("GET", "/", nil), 200, 0) {
+		t.Error("trace not kept with probability")
+	}
+}
+
+func TestTailSamplerDecide(t *testing.T) {
+	ts, reporter, _, tr := newTestTailSampler(t, TailSamplingConfig{})
+	kept, dropped := testutil.ToFloat64(tracingMetrics.tailTraces.WithLabelValues("kept")),
+		testutil.ToFloat64(tracingMetrics.tailTraces.WithLabelValues("dropped"))
+
+	keep := finishTrace(tr)
+	drop := finishTrace(tr)
+	if n := reporter.SpansSubmitted(); n != 0 {
+		t.Fatalf("%d spans reported before the decision", n)
+	}
+	ts.decide(keep, true)
+	ts.decide(drop, false)
+	if n := reporter.SpansSubmitted(); n != 1 {
+		t.Fatalf("%d spans reported, want 1", n)
+	}
+
+	// spans finishing after the decision follow it
+	finishChild(tr, keep)
+	finishChild(tr, drop)
+	if n := reporter.SpansSubmitted(); n != 2 {
+		t.Errorf("%d spans reported after late spans, want 2", n)
+	}
+
+	if d := testutil.ToFloat64(tracingMetrics.tailTraces.WithLabelValues("kept")) - kept; d != 1 {
+		t.Errorf("kept traces increased by %v, want 1", d)
+	}
+	if d := testutil.ToFloat64(tracingMetrics.tailTraces.WithLabelValues("dropped")) - dropped; d != 1 {
+		t.Errorf("dropped traces increased by %v, want 1", d)
+	}
+}
+
+func TestTailSamplerMaxSpansPerTrace(t *testing.T) {
+	ts, reporter, _, tr := newTestTailSampler(t, TailSamplingConfig{MaxSpansPerTrace: 2})
+	droppedSpans := testutil.ToFloat64(tracingMetrics.tailDroppedSpans)
+
+	id := finishTrace(tr)
+	finishChild(tr, id)
+	finishChild(tr, id)
+	ts.decide(id, true)
+	if n := reporter.SpansSubmitted(); n != 2 {
+		t.Errorf("%d spans reported, want 2", n)
+	}
+	if d := testutil.ToFloat64(tracingMetrics.tailDroppedSpans) - droppedSpans; d != 1 {
+		t.Errorf("dropped spans increased by %v, want 1", d)
+	}
+}
+
+func TestTailSamplerExpiry(t *testing.T) {
+	ts, reporter, now, tr := newTestTailSampler(t, TailSamplingConfig{
+		DecisionWait: caddy.Duration(10 * time.Second),
+	})
+	evicted := testutil.ToFloat64(tracingMetrics.tailTraces.WithLabelValues("evicted"))
+
+	undecided := finishTrace(tr)
+	*now = now.Add(5 * time.Second)
+	decided := finishTrace(tr)
+	ts.decide(decided, true)
+
+	// the undecided trace expires DecisionWait after it was created
+	*now = now.Add(6 * time.Second)
+	finishTrace(tr)
+	if _, ok := ts.traces[undecided]; ok {
+		t.Error("undecided trace not expired")
+	}
+	if d := testutil.ToFloat64(tracingMetrics.tailTraces.WithLabelValues("evicted")) - evicted; d != 1 {
+		t.Errorf("evicted traces increased by %v, want 1", d)
+	}
+
+	// the decision is kept DecisionWait after it was made
+	finishChild(tr, decided)
+	if n := reporter.SpansSubmitted(); n != 2 {
+		t.Errorf("%d spans reported, want 2", n)
+	}
+	*now = now.Add(5 * time.Second)
+	finishChild(tr, decided)
+	if n := reporter.SpansSubmitted(); n != 2 {
+		t.Errorf("late span after the decision expired was reported")
+	}
+}
+
+func TestTailSamplerMaxTraces(t *testing.T) {
+	ts, _, now, tr := newTestTailSampler(t, TailSamplingConfig{MaxTraces: 3})
+	buffered := testutil.ToFloat64(tracingMetrics.tailBuffered)
+
+	first := finishTrace(tr)
+	*now = now.Add(time.Second)
+	second := finishTrace(tr)
+	*now = now.Add(time.Second)
+	third := finishTrace(tr)
+	ts.decide(third, false)
+
+	// the decided trace is evicted before the older undecided ones
+	fourth := finishTrace(tr)
+	for _, id := range []jaeger.TraceID{first, second, fourth} {
+		if _, ok := ts.traces[id]; !ok {
+			t.Errorf("undecided trace %s evicted", id)
+		}
+	}
+	if _, ok := ts.traces[third]; ok {
+		t.Error("decided trace not evicted")
+	}
+
+	// then the oldest undecided trace
+	finishTrace(tr)
+	if _, ok := ts.traces[first]; ok {
+		t.Error("oldest undecided trace not evicted")
+	}
+	if _, ok := ts.traces[second]; !ok {
+		t.Error("undecided trace evicted before the oldest")
+	}
+
+	if d := testutil.ToFloat64(tracingMetrics.tailBuffered) - buffered; d != 3 {
+		t.Errorf("buffered traces increased by %v, want 3", d)
+	}
+	ts.Close()
+	if d := testutil.ToFloat64(tracingMetrics.tailBuffered) - buffered; d != 0 {
+		t.Errorf("buffered traces increased by %v after Close, want 0", d)
+	}
+}
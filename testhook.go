@@ -0,0 +1,35 @@
+package opentracing
+
+import (
+	"sync"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+// testReporter replaces the configured reporter when set, see SetTestReporter.
+var testReporter struct {
+	sync.Mutex
+	reporter jaeger.Reporter
+}
+
+// SetTestReporter makes the handlers provisioned from now on report their
+// spans to reporter instead of the configured agent or collector. It returns
+// a function restoring the previous reporter. It is meant for tests, see the
+// tracingtest package.
+func SetTestReporter(reporter jaeger.Reporter) (restore func()) {
+	testReporter.Lock()
+	defer testReporter.Unlock()
+	previous := testReporter.reporter
+	testReporter.reporter = reporter
+	return func() {
+		testReporter.Lock()
+		testReporter.reporter = previous
+		testReporter.Unlock()
+	}
+}
+
+func currentTestReporter() jaeger.Reporter {
+	testReporter.Lock()
+	defer testReporter.Unlock()
+	return testReporter.reporter
+}
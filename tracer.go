@@ -1,14 +1,21 @@
 package opentracing
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"os"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
 
@@ -20,17 +27,165 @@ const (
 	defaultComponentName = "caddy.module.opentracing"
 	defaultServiceName   = "caddy"
 	responseSizeKey      = "http.response_size"
+	traceIDVarKey        = "trace_id"
+	spanIDVarKey         = "span_id"
 )
 
+// Opentracing traces the requests it handles and propagates the span to the
+// handlers that follow through the request context.
+//
+// The following placeholders are set for the handlers that follow, unless
+// the sampler dropped the trace; use ResponseHeader or LogFields to get the
+// IDs of every trace:
+//
+// Placeholder | Description
+// ------------|-------------
+// `{http.opentracing.trace_id}` | The trace ID of the request span
+// `{http.opentracing.span_id}` | The span ID of the request span
+// `{http.opentracing.sampled}` | Whether the request span is sampled
 type Opentracing struct {
 	Config
-	tr     opentracing.Tracer
-	opts   Options
-	closer io.Closer
+
+	// EnvOverrides is how the JAEGER_* environment variables apply to the
+	// tracer config. A configured service name always takes precedence.
+	// - "override" replaces the configured values (default)
+	// - "fill_missing" only sets the values left empty in the config,
+	//   which never includes the booleans, e.g. disabled or rpc_metrics
+	// - "ignore" does not read them
+	EnvOverrides string `json:"env_overrides"`
+
+	// ComponentName is the value of the component tag of the request spans.
+	// Default caddy.module.opentracing.
+	ComponentName string `json:"component_name"`
+
+	// OperationName is the name of the request spans, which may contain
+	// request placeholders. Default {http.request.method} {http.request.uri.path}.
+	OperationName string `json:"operation_name"`
+
+	// SkipMatchersRaw leaves the requests matching one of the matcher sets
+	// untraced.
+	SkipMatchersRaw caddyhttp.RawMatcherSets `json:"skip" caddy:"namespace=http.matchers"`
+
+	// UpstreamSpans records a client span for every upstream attempt
+	// made by the handlers that follow, e.g. each retry of reverse_proxy.
+	UpstreamSpans bool `json:"upstream_spans"`
+
+	// ResponseHeader is the name of a response header, e.g. X-Trace-Id,
+	// to which the trace ID of the request span is written.
+	ResponseHeader string `json:"response_header"`
+
+	// LogFields sets the trace_id and span_id variables of the request for
+	// the handlers that follow, e.g. {http.vars.trace_id}. Caddy 2.5 does
+	// not write the variables to the access log; to log the trace ID, set
+	// ResponseHeader, since the access log records the response headers.
+	LogFields bool `json:"log_fields"`
+
+	// LogFieldsSampledOnly limits LogFields to sampled traces, so that the
+	// logged IDs always link to a recorded trace. It cannot be used with
+	// TailSampling, which decides whether a trace is kept after the request.
+	LogFieldsSampledOnly bool `json:"log_fields_sampled_only"`
+
+	// TailSampling enables tail-based sampling, which replaces the sampler.
+	// It cannot be used with SamplingRules, since the traces a rule leaves
+	// unsampled never reach the tail sampler.
+	TailSampling *TailSamplingConfig `json:"tail_sampling"`
+
+	// SamplingRules decide locally which requests start a sampled trace,
+	// overriding the sampler for the requests they match. They cannot be
+	// used with TailSampling.
+	SamplingRules []*SamplingRule `json:"sampling_rules"`
+
+	// ForceSample forces the requests carrying a signed token to be sampled.
+	// When it is set, the Jaeger debug header of requests is ignored.
+	ForceSample *ForceSampleConfig `json:"force_sample"`
+
+	// TrustContext restricts which requests may propagate their incoming
+	// trace context. Every request is trusted when it is not set.
+	TrustContext *TrustConfig `json:"trust_context"`
+
+	// Baggage controls the baggage accepted from incoming requests.
+	Baggage *BaggageConfig `json:"baggage"`
+
+	// Redact removes sensitive data from the http.url tag, the default
+	// operation name and the tags whose values come from request headers.
+	Redact *RedactConfig `json:"redact"`
+
+	// RequestHeaders lists the request headers recorded as
+	// http.request.header.<name> tags.
+	RequestHeaders []string `json:"request_headers"`
+
+	// ResponseHeaders lists the response headers recorded as
+	// http.response.header.<name> tags, as they are when the header
+	// is written. Headers carrying credentials are never recorded.
+	ResponseHeaders []string `json:"response_headers"`
+
+	// BodyCapture records the beginning of the request and response bodies
+	// as span logs. It is disabled by default.
+	BodyCapture *BodyCaptureConfig `json:"body_capture"`
+
+	// ProtocolTags lists the HTTP and TLS details to record as span tags:
+	// protocol, tls_version, tls_cipher, tls_server_name, tls_alpn and
+	// tls_client_subject.
+	ProtocolTags []string `json:"protocol_tags"`
+
+	// TrustedProxies lists the IP addresses or CIDR ranges of the proxies
+	// whose X-Forwarded-For header is used to tag the client IP address.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// SetGlobal makes the tracer the global tracer of opentracing-go, for
+	// the libraries calling opentracing.GlobalTracer().
+	SetGlobal bool `json:"set_global"`
+
+	// SiteServiceName names the service of each request from request
+	// placeholders, e.g. {http.request.host}, so that every site shows up
+	// as its own service. Every service gets its own tracer, sharing the
+	// sampler of the handler, up to MaxSiteTracers. The requests of further
+	// services are traced as ServiceName, tagged with site.service.
+	SiteServiceName string `json:"site_service_name"`
+
+	// MaxSiteTracers bounds the number of tracers of SiteServiceName.
+	// Default 16.
+	MaxSiteTracers int `json:"max_site_tracers"`
+
+	// SiteServices lists the service names of SiteServiceName which get
+	// their own tracer. When empty, the first services seen get one, so
+	// requests with arbitrary Host headers can take every tracer when
+	// SiteServiceName depends on the host.
+	SiteServices []string `json:"site_services"`
+
+	tr          opentracing.Tracer
+	opts        Options
+	closer      io.Closer
+	tail        *tailSampler
+	sampler     *overridableSampler
+	force       *forceSampler
+	headers     jaeger.HeadersConfig
+	reqHeaders  []capturedHeader
+	respHeaders []capturedHeader
+	protoTags   protocolTags
+	skip        caddyhttp.MatcherSets
+	sites       *siteTracers
+	proxies     []*net.IPNet
+	hostName    string
+	rpcMetrics  bool
+	serviceName string
+
+	// cfgCtx identifies the configuration the handler was provisioned in.
+	cfgCtx context.Context
+
+	// prevGlobal is the handler whose tracer was the global tracer before
+	// this one replaced it, see setGlobalTracer.
+	prevGlobal *Opentracing
 }
 
 // Validate implements caddy.Validator.
 func (tracing *Opentracing) Validate() (err error) {
+	if tracing.LogFieldsSampledOnly && tracing.TailSampling != nil {
+		return fmt.Errorf("log_fields sampled_only cannot be used with tail_sampling, which keeps traces after the request")
+	}
+	if len(tracing.SamplingRules) > 0 && tracing.TailSampling != nil {
+		return fmt.Errorf("sampling_rules cannot be used with tail_sampling, which only sees the traces sampled by the rules")
+	}
 	return nil
 }
 
@@ -46,28 +201,264 @@ func (tracing Opentracing) CaddyModule() caddy.ModuleInfo {
 
 // Implements caddy.Provisioner.
 func (tracing *Opentracing) Provision(ctx caddy.Context) (err error) {
-	var cfg *config.Configuration
-	if cfg, err = tracing.Config.ToTracingConfig().FromEnv(); err != nil {
+	cfg := tracing.Config.ToTracingConfig()
+	if err = expandPlaceholders(cfg); err != nil {
+		return
+	}
+	serviceName := cfg.ServiceName
+	if cfg, err = applyEnv(cfg, tracing.EnvOverrides); err != nil {
 		return
 	}
 
 	if cfg.ServiceName == "" {
 		cfg.ServiceName = defaultServiceName
 	}
+	if serviceName != "" {
+		cfg.ServiceName = serviceName
+	}
+	tracing.serviceName = cfg.ServiceName
+	ctx.Logger(tracing).Debug("tracer config", configFields(cfg)...)
+	if cfg.Headers != nil {
+		tracing.headers = *cfg.Headers
+	}
+	tracing.headers.ApplyDefaults()
+
+	for i, rule := range tracing.SamplingRules {
+		if err = rule.provision(ctx); err != nil {
+			return fmt.Errorf("sampling rule %d: %v", i, err)
+		}
+	}
+
+	if tracing.ForceSample != nil {
+		if tracing.force, err = newForceSampler(*tracing.ForceSample); err != nil {
+			return fmt.Errorf("force_sample: %v", err)
+		}
+	}
+
+	if tracing.TrustContext != nil {
+		if err = tracing.TrustContext.provision(ctx); err != nil {
+			return fmt.Errorf("trust_context: %v", err)
+		}
+	}
+
+	if tracing.Redact != nil {
+		if err = tracing.Redact.provision(); err != nil {
+			return fmt.Errorf("redact: %v", err)
+		}
+	}
+
+	if tracing.BodyCapture != nil {
+		if err = tracing.BodyCapture.provision(ctx); err != nil {
+			return fmt.Errorf("body_capture: %v", err)
+		}
+	}
+
+	tracing.reqHeaders = capturedHeaders(tracing.RequestHeaders, requestHeaderTagPrefix)
+	tracing.respHeaders = capturedHeaders(tracing.ResponseHeaders, responseHeaderTagPrefix)
+	if tracing.protoTags, err = newProtocolTags(tracing.ProtocolTags); err != nil {
+		return fmt.Errorf("protocol_tags: %v", err)
+	}
+	for _, proxy := range tracing.TrustedProxies {
+		var ipNet *net.IPNet
+		if ipNet, err = parseCIDR(proxy); err != nil {
+			return fmt.Errorf("trusted_proxies: %v", err)
+		}
+		tracing.proxies = append(tracing.proxies, ipNet)
+	}
+	tracing.hostName, _ = os.Hostname()
+
+	var mods interface{}
+	if mods, err = ctx.LoadModule(tracing, "SkipMatchersRaw"); err != nil {
+		return fmt.Errorf("loading skip matchers: %v", err)
+	}
+	if err = tracing.skip.FromInterface(mods); err != nil {
+		return
+	}
+
+	if !cfg.Disabled {
+		var sampler jaeger.Sampler
+		if sampler, err = tracing.newSampler(cfg); err != nil {
+			return
+		}
+		if tracing.sampler, err = newOverridableSampler(sampler); err != nil {
+			return
+		}
+	}
 
-	if tracing.tr, tracing.closer, err = cfg.New(tracing.Config.ServiceName); err != nil {
+	var tracer siteTracer
+	if tracer, err = tracing.newTracer(cfg, tracing.sampler); err != nil {
 		return
 	}
+	tracing.tr, tracing.closer, tracing.tail = tracer.tr, tracer.closer, tracer.tail
+	tracing.rpcMetrics = cfg.RPCMetrics
+
+	if tracing.SiteServiceName != "" && !cfg.Disabled {
+		max := tracing.MaxSiteTracers
+		if max <= 0 {
+			max = defaultMaxSiteTracers
+		}
+		tracing.sites = newSiteTracers(max, tracing.SiteServices, ctx.Logger(tracing), func(serviceName string) (siteTracer, error) {
+			siteCfg := *cfg
+			siteCfg.ServiceName = serviceName
+			sampler, err := newOverridableSampler(sharedSampler{tracing.sampler})
+			if err != nil {
+				return siteTracer{}, err
+			}
+			t, err := tracing.newTracer(&siteCfg, sampler)
+			t.sampler = sampler
+			return t, err
+		})
+	}
+	tracing.cfgCtx = ctx.Context
+	registerHandler(tracing)
+	if tracing.SetGlobal {
+		setGlobalTracer(tracing)
+	}
 
 	tracing.opts = Options{
 		opNameFunc: func(r *http.Request) string {
-			return r.Method + " " + r.URL.Path
-		},
-		spanFilter:   func(r *http.Request) bool { return true },
-		spanObserver: func(span opentracing.Span, r *http.Request) {},
-		urlTagFunc: func(u *url.URL) string {
-			return u.String()
+			path, _ := tracing.Redact.path(r.URL)
+			return r.Method + " " + path
 		},
+		spanFilter:    func(r *http.Request) bool { return true },
+		spanObserver:  func(span opentracing.Span, r *http.Request) {},
+		urlTagFunc:    tracing.Redact.url,
+		componentName: tracing.ComponentName,
+	}
+	if tracing.OperationName != "" {
+		tracing.opts.opNameFunc = func(r *http.Request) string {
+			repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+			return repl.ReplaceAll(tracing.OperationName, "")
+		}
+	}
+	if len(tracing.skip) > 0 {
+		tracing.opts.spanFilter = func(r *http.Request) bool {
+			return !tracing.skip.AnyMatch(r)
+		}
+	}
+	return nil
+}
+
+// disabled reports whether tr is the no-op tracer, which jaeger returns by
+// pointer when the config is disabled.
+func disabled(tr opentracing.Tracer) bool {
+	switch tr.(type) {
+	case opentracing.NoopTracer, *opentracing.NoopTracer:
+		return true
+	}
+	return false
+}
+
+// newTracer creates a tracer for cfg, with its own reporter and the given
+// sampler.
+func (tracing *Opentracing) newTracer(cfg *config.Configuration, sampler jaeger.Sampler) (t siteTracer, err error) {
+	var options []config.Option
+	if !cfg.Disabled {
+		reporter := currentTestReporter()
+		if tracing.TailSampling != nil {
+			if reporter == nil {
+				if cfg.Reporter == nil {
+					cfg.Reporter = &config.ReporterConfig{}
+				}
+				if reporter, err = cfg.Reporter.NewReporter(cfg.ServiceName, jaeger.NewNullMetrics(), jaeger.NullLogger); err != nil {
+					return
+				}
+			}
+			t.tail = newTailSampler(*tracing.TailSampling, reporter)
+			reporter = t.tail
+		}
+		if reporter != nil {
+			options = append(options, config.Reporter(reporter))
+		}
+		options = append(options, config.Sampler(sampler))
+	}
+	if len(tracing.SamplingRules) > 0 {
+		// a rule forcing a trace to be sampled must not mark it as debug
+		options = append(options, config.NoDebugFlagOnForcedSampling(true))
+	}
+
+	t.tr, t.closer, err = cfg.NewTracer(options...)
+	return
+}
+
+// newSampler creates the sampler of the tracer.
+func (tracing *Opentracing) newSampler(cfg *config.Configuration) (sampler jaeger.Sampler, err error) {
+	if tracing.TailSampling != nil {
+		// every span is recorded, and the tail sampler decides which are reported
+		return jaeger.NewConstSampler(true), nil
+	}
+	if cfg.Sampler == nil {
+		cfg.Sampler = &config.SamplerConfig{
+			Type:  jaeger.SamplerTypeRemote,
+			Param: defaultSamplingProbability,
+		}
+	}
+	if tracing.Config.Sampler == nil || tracing.Config.Sampler.StrategiesFile == "" {
+		return cfg.Sampler.NewSampler(cfg.ServiceName, jaeger.NewNullMetrics())
+	}
+
+	source := &fileStrategySource{path: tracing.Config.Sampler.StrategiesFile, service: cfg.ServiceName}
+	var body []byte
+	if body, err = source.Fetch(cfg.ServiceName); err != nil {
+		return
+	}
+	if _, err = source.Parse(body); err != nil {
+		return
+	}
+
+	cfg.Sampler.Type = jaeger.SamplerTypeRemote
+	cfg.Sampler.Options = append(cfg.Sampler.Options,
+		jaeger.SamplerOptions.SamplingStrategyFetcher(source),
+		jaeger.SamplerOptions.SamplingStrategyParser(source),
+	)
+	if sampler, err = cfg.Sampler.NewSampler(cfg.ServiceName, jaeger.NewNullMetrics()); err != nil {
+		return
+	}
+	// apply the file right away rather than after the first refresh interval
+	sampler.(*jaeger.RemotelyControlledSampler).UpdateSampler()
+	return sampler, nil
+}
+
+// extract returns the incoming trace context of r which may be continued,
+// and the untrusted context it replaces, if any.
+func (tracing *Opentracing) extract(r *http.Request) (ctx, untrusted opentracing.SpanContext) {
+	if tracing.force != nil {
+		r.Header.Del(tracing.headers.JaegerDebugHeader)
+	}
+	if _, isJaeger := tracing.tr.(*jaeger.Tracer); isJaeger && !tracing.hasContextHeaders(r) {
+		return nil, nil
+	}
+	ctx, _ = tracing.tr.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+
+	if tc := tracing.TrustContext; tc != nil && !tc.trusted(r) {
+		if tc.StripHeaders || tc.Untrusted != untrustedHonor {
+			tracing.stripContextHeaders(r)
+		}
+		switch tc.Untrusted {
+		case untrustedRestart:
+			return nil, ctx
+		case untrustedDrop:
+			return nil, nil
+		}
+	}
+
+	if ctx != nil && tracing.Baggage != nil {
+		ctx = tracing.Baggage.filter(ctx, r.Header, &tracing.headers)
+	}
+	return ctx, nil
+}
+
+// Cleanup implements caddy.CleanerUpper.
+func (tracing *Opentracing) Cleanup() error {
+	unregisterHandler(tracing)
+	if tracing.SetGlobal {
+		resetGlobalTracer(tracing)
+	}
+	if tracing.sites != nil {
+		tracing.sites.Close()
+	}
+	if tracing.closer != nil {
+		return tracing.closer.Close()
 	}
 	return nil
 }
@@ -80,39 +471,165 @@ type Options struct {
 	componentName string
 }
 
-func (tracing Opentracing) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) (err error) {
-	tr := tracing.tr
-	opts := tracing.opts
+func (tracing *Opentracing) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) (err error) {
+	tr, tail := tracing.tr, tracing.tail
+	opts := &tracing.opts
+
+	if disabled(tr) || !opts.spanFilter(r) {
+		return next.ServeHTTP(w, r)
+	}
 
-	componentName := opts.componentName
-	if componentName == "" {
-		componentName = defaultComponentName
+	var unpooledSite string
+	if tracing.sites != nil {
+		if repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+			if name := repl.ReplaceAll(tracing.SiteServiceName, ""); name != "" {
+				if site, ok := tracing.sites.get(name); ok {
+					tr, tail = site.tr, site.tail
+				} else {
+					unpooledSite = name
+				}
+			}
+		}
 	}
 
-	if !opts.spanFilter(r) {
-		return next.ServeHTTP(w, r)
+	start := time.Now()
+	ctx, untrusted := tracing.extract(r)
+	var startOpts []opentracing.StartSpanOption
+	if ctx != nil {
+		startOpts = append(startOpts, opentracing.ChildOf(ctx))
+	}
+	if tracing.rpcMetrics {
+		// the RPC metrics observer reads the kind of the span when it starts
+		startOpts = append(startOpts, ext.SpanKindRPCServer)
+	}
+	if tracing.force != nil && tracing.force.forced(r) {
+		startOpts = append(startOpts, opentracing.Tag{Key: string(ext.SamplingPriority), Value: uint16(1)})
+	} else if !continuesTrace(ctx) {
+		for _, rule := range tracing.SamplingRules {
+			if rule.match(r) {
+				var priority uint16
+				if rule.sample() {
+					priority = 1
+				}
+				startOpts = append(startOpts, opentracing.Tag{Key: string(ext.SamplingPriority), Value: priority})
+				break
+			}
+		}
+	}
+	sp := tr.StartSpan(opts.opNameFunc(r), startOpts...)
+	sc := sp.Context()
+	sampled, dropped := contextSampling(sc)
+
+	if !dropped {
+		componentName := opts.componentName
+		if componentName == "" {
+			componentName = defaultComponentName
+		}
+		if untrusted != nil {
+			if traceID, spanID, _, ok := contextIdentity(untrusted); ok {
+				sp.SetTag(linkTraceIDKey, traceID)
+				sp.SetTag(linkSpanIDKey, spanID)
+			}
+		}
+		if !tracing.rpcMetrics {
+			ext.SpanKindRPCServer.Set(sp)
+		}
+		ext.HTTPMethod.Set(sp, r.Method)
+		ext.HTTPUrl.Set(sp, opts.urlTagFunc(r.URL))
+		ext.Component.Set(sp, componentName)
+		if unpooledSite != "" {
+			sp.SetTag(siteServiceKey, unpooledSite)
+		}
+		tracing.protoTags.set(sp, r)
+		tracing.setPeerTags(sp, r)
+		for _, h := range tracing.reqHeaders {
+			if v := r.Header.Get(h.name); v != "" {
+				sp.SetTag(h.tag, tracing.Redact.value(v))
+			}
+		}
+		if tracing.Baggage != nil {
+			tracing.Baggage.exposeTags(sp, tracing.Redact)
+		}
+		opts.spanObserver(sp, r)
+	}
+	if tracing.Baggage != nil {
+		tracing.Baggage.exposeHeaders(sp, r)
+	}
+
+	if !dropped {
+		if repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+			repl.Map(spanPlaceholders(sp))
+		}
+	}
+	if tracing.ResponseHeader != "" || tracing.LogFields {
+		if traceID, spanID, sampled, ok := contextIdentity(sc); ok {
+			if tracing.ResponseHeader != "" {
+				w.Header().Set(tracing.ResponseHeader, traceID)
+			}
+			if tracing.LogFields && (sampled || !tracing.LogFieldsSampledOnly) {
+				caddyhttp.SetVar(r.Context(), traceIDVarKey, traceID)
+				caddyhttp.SetVar(r.Context(), spanIDVarKey, spanID)
+			}
+		}
 	}
 
-	ctx, _ := tr.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
-	sp := tr.StartSpan(opts.opNameFunc(r), ext.RPCServerOption(ctx))
-	ext.HTTPMethod.Set(sp, r.Method)
-	ext.HTTPUrl.Set(sp, opts.urlTagFunc(r.URL))
-	ext.Component.Set(sp, componentName)
-	opts.spanObserver(sp, r)
+	var reqCtx context.Context = &serverSpanContext{Context: r.Context(), sp: sp}
+	var ut *upstreamTracer
+	if tracing.UpstreamSpans && !dropped {
+		ut = newUpstreamTracer(tr, sp, r)
+		reqCtx = httptrace.WithClientTrace(reqCtx, ut.clientTrace())
+	}
+	r = r.WithContext(reqCtx)
+
+	mt := trackerPool.Get().(*metricsTracker)
+	defer putTracker(mt)
+	mt.ResponseWriter = w
+	if !dropped {
+		mt.captureHeaders = tracing.respHeaders
+	}
 
-	r = r.WithContext(opentracing.ContextWithSpan(r.Context(), sp))
-	mt := &metricsTracker{ResponseWriter: w}
+	var reqBody *bodyBuffer
+	captureBody := sampled && tracing.BodyCapture != nil && tracing.BodyCapture.match(r)
+	if captureBody {
+		if r.Body != nil && r.Body != http.NoBody {
+			reqBody = &bodyBuffer{max: tracing.BodyCapture.MaxBytes}
+			r.Body = bodyRecorder{ReadCloser: r.Body, bodyBuffer: reqBody}
+		}
+		mt.body = &bodyBuffer{max: tracing.BodyCapture.MaxBytes}
+	}
 
 	err = next.ServeHTTP(mt, r)
+	if ut != nil {
+		ut.finish(mt.status)
+	}
+	if dropped {
+		sp.Finish()
+		return err
+	}
+
 	if mt.status > 0 {
 		ext.HTTPStatusCode.Set(sp, uint16(mt.status))
 	}
 	if mt.size > 0 {
 		sp.SetTag(responseSizeKey, mt.size)
 	}
+	if captureBody {
+		tracing.BodyCapture.log(sp, "http.request.body", r.Header.Get("Content-Type"), reqBody, tracing.Redact)
+		tracing.BodyCapture.log(sp, "http.response.body", mt.Header().Get("Content-Type"), mt.body, tracing.Redact)
+	}
+	for i, v := range mt.headerValues {
+		if v != "" {
+			sp.SetTag(tracing.respHeaders[i].tag, tracing.Redact.value(v))
+		}
+	}
 	if mt.status >= http.StatusInternalServerError {
 		ext.Error.Set(sp, true)
 	}
+	if tail != nil {
+		if jsc, ok := sc.(jaeger.SpanContext); ok {
+			tail.decide(jsc.TraceID(), tail.keep(r, mt.status, time.Since(start)))
+		}
+	}
 	sp.Finish()
 	return err
 }
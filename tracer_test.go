@@ -0,0 +1,418 @@
+package opentracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/uber/jaeger-client-go"
+)
+
+// provision provisions tracing like Caddy does, and cleans it up when the
+// test ends.
+func provision(t testing.TB, tracing *Opentracing) *Opentracing {
+	t.Helper()
+	return provisionIn(t, newContext(t), tracing)
+}
+
+// newContext returns a new configuration context, canceled when the test
+// ends.
+func newContext(t testing.TB) caddy.Context {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// provisionIn provisions tracing in the configuration of ctx.
+func provisionIn(t testing.TB, ctx caddy.Context, tracing *Opentracing) *Opentracing {
+	t.Helper()
+	if err := tracing.Provision(ctx); err != nil {
+		t.Fatalf("provisioning: %v", err)
+	}
+	t.Cleanup(func() { tracing.Cleanup() })
+	if err := tracing.Validate(); err != nil {
+		t.Fatalf("validating: %v", err)
+	}
+	return tracing
+}
+
+// provisionMock provisions tracing, then replaces its tracer with a mock
+// tracer recording the finished spans.
+func provisionMock(t testing.TB, tracing *Opentracing) *mocktracer.MockTracer {
+	t.Helper()
+	if tracing.Sampler == nil {
+		tracing.Sampler = constSampler(true)
+	}
+	if tracing.EnvOverrides == "" {
+		tracing.EnvOverrides = envIgnore
+	}
+	provision(t, tracing)
+	tr := mocktracer.New()
+	tracing.tr = tr
+	return tr
+}
+
+// constSampler returns the config of a const sampler.
+func constSampler(sampled bool) *SamplerConfig {
+	param := 0.0
+	if sampled {
+		param = 1
+	}
+	return &SamplerConfig{Type: "const", Param: param}
+}
+
+// nopHandler is a next handler writing nothing.
+var nopHandler = caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+// serve sends r through tracing to next, with the request context Caddy
+// sets up, and returns the recorded response.
+func serve(t testing.TB, tracing *Opentracing, r *http.Request, next caddyhttp.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	repl := caddy.NewReplacer()
+	ctx := context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl)
+	ctx = context.WithValue(ctx, caddyhttp.VarsCtxKey, make(map[string]interface{}))
+	w := httptest.NewRecorder()
+	if err := tracing.ServeHTTP(w, r.WithContext(ctx), next); err != nil {
+		t.Fatalf("serving: %v", err)
+	}
+	return w
+}
+
+func TestLogFields(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		sampled     bool
+		sampledOnly bool
+		wantVars    bool
+	}{
+		{name: "sampled", sampled: true, wantVars: true},
+		{name: "unsampled", sampled: false, wantVars: true},
+		{name: "sampled only", sampled: true, sampledOnly: true, wantVars: true},
+		{name: "unsampled sampled only", sampled: false, sampledOnly: true, wantVars: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracing := provision(t, &Opentracing{
+				Config:               Config{Sampler: constSampler(tc.sampled)},
+				LogFields:            true,
+				LogFieldsSampledOnly: tc.sampledOnly,
+				EnvOverrides:         envIgnore,
+			})
+			var traceID interface{}
+			serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) error {
+				traceID = caddyhttp.GetVar(r.Context(), traceIDVarKey)
+				return nil
+			})
+			if got := traceID != nil; got != tc.wantVars {
+				t.Errorf("trace_id var set = %v, want %v", got, tc.wantVars)
+			}
+		})
+	}
+}
+
+func TestValidateLogFieldsWithTailSampling(t *testing.T) {
+	tracing := &Opentracing{
+		LogFields:            true,
+		LogFieldsSampledOnly: true,
+		TailSampling:         &TailSamplingConfig{},
+	}
+	if err := tracing.Validate(); err == nil {
+		t.Error("log_fields sampled_only with tail_sampling is valid")
+	}
+}
+
+func TestValidateSamplingRulesWithTailSampling(t *testing.T) {
+	tracing := &Opentracing{
+		SamplingRules: []*SamplingRule{{Type: "const", Param: 1}},
+		TailSampling:  &TailSamplingConfig{},
+	}
+	if err := tracing.Validate(); err == nil {
+		t.Error("sampling_rules with tail_sampling is valid")
+	}
+}
+
+func TestServeHTTPSpan(t *testing.T) {
+	tracing := &Opentracing{}
+	tr := provisionMock(t, tracing)
+
+	var next *mocktracer.MockSpan
+	serve(t, tracing, httptest.NewRequest(http.MethodPost, "http://example.com/path?q=1", nil), func(w http.ResponseWriter, r *http.Request) error {
+		next, _ = opentracing.SpanFromContext(r.Context()).(*mocktracer.MockSpan)
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte("hello"))
+		return err
+	})
+
+	spans := tr.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans finished, want 1", len(spans))
+	}
+	sp := spans[0]
+	if next != sp {
+		t.Error("the next handler did not get the span")
+	}
+	if sp.OperationName != "POST /path" {
+		t.Errorf("operation name = %q, want %q", sp.OperationName, "POST /path")
+	}
+	if sp.ParentID != 0 {
+		t.Errorf("parent = %d, want none", sp.ParentID)
+	}
+	for key, want := range map[string]interface{}{
+		"span.kind":        ext.SpanKindRPCServerEnum,
+		"http.method":      http.MethodPost,
+		"http.url":         "http://example.com/path?q=1",
+		"component":        defaultComponentName,
+		"http.status_code": uint16(http.StatusCreated),
+		responseSizeKey:    5,
+	} {
+		if got := sp.Tag(key); got != want {
+			t.Errorf("tag %s = %v (%T), want %v (%T)", key, got, got, want, want)
+		}
+	}
+	if got := sp.Tag("error"); got != nil {
+		t.Errorf("tag error = %v, want none", got)
+	}
+}
+
+func TestServeHTTPStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		status    int
+		body      string
+		wantSize  interface{}
+		wantError interface{}
+	}{
+		{name: "implicit", body: "ok", wantSize: 2},
+		{name: "empty", status: http.StatusNoContent},
+		{name: "client error", status: http.StatusNotFound, body: "not found", wantSize: 9},
+		{name: "server error", status: http.StatusBadGateway, wantError: true},
+		{name: "early hints", status: http.StatusEarlyHints, body: "ok", wantSize: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracing := &Opentracing{}
+			tr := provisionMock(t, tracing)
+			serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) error {
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				if tc.body != "" {
+					_, _ = w.Write([]byte(tc.body))
+				}
+				return nil
+			})
+
+			sp := tr.FinishedSpans()[0]
+			wantStatus := tc.status
+			if wantStatus < http.StatusOK {
+				wantStatus = http.StatusOK
+			}
+			if got := sp.Tag("http.status_code"); got != uint16(wantStatus) {
+				t.Errorf("status = %v, want %d", got, wantStatus)
+			}
+			if got := sp.Tag(responseSizeKey); got != tc.wantSize {
+				t.Errorf("size = %v, want %v", got, tc.wantSize)
+			}
+			if got := sp.Tag("error"); got != tc.wantError {
+				t.Errorf("error = %v, want %v", got, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestServeHTTPParent(t *testing.T) {
+	tracing := &Opentracing{}
+	tr := provisionMock(t, tracing)
+
+	parent := tr.StartSpan("client")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := tr.Inject(parent.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header)); err != nil {
+		t.Fatal(err)
+	}
+	serve(t, tracing, r, nopHandler)
+
+	sp := tr.FinishedSpans()[0]
+	pc := parent.Context().(mocktracer.MockSpanContext)
+	if sp.SpanContext.TraceID != pc.TraceID || sp.ParentID != pc.SpanID {
+		t.Errorf("span %v is not a child of %v", sp.SpanContext, pc)
+	}
+}
+
+func TestServeHTTPJaegerParent(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	defer SetTestReporter(reporter)()
+	tracing := provision(t, &Opentracing{
+		Config:       Config{Sampler: constSampler(false)},
+		EnvOverrides: envIgnore,
+	})
+
+	// the sampling decision of the parent is honored too
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Uber-Trace-Id", "1f:2f:0:1")
+	serve(t, tracing, r, nopHandler)
+
+	spans := reporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans reported, want 1", len(spans))
+	}
+	sc := spans[0].(*jaeger.Span).SpanContext()
+	if sc.TraceID() != (jaeger.TraceID{Low: 0x1f}) || sc.ParentID() != 0x2f {
+		t.Errorf("span %v is not a child of 1f:2f", sc)
+	}
+}
+
+func TestServeHTTPSkip(t *testing.T) {
+	tracing := &Opentracing{
+		SkipMatchersRaw: caddyhttp.RawMatcherSets{
+			{"path": json.RawMessage(`["/health"]`)},
+		},
+	}
+	tr := provisionMock(t, tracing)
+
+	var called bool
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/health", nil), func(w http.ResponseWriter, r *http.Request) error {
+		called = true
+		if sp := SpanFromRequest(r); sp != nil {
+			t.Error("skipped request has a span")
+		}
+		return nil
+	})
+	if !called {
+		t.Error("the next handler was not called")
+	}
+	if n := len(tr.FinishedSpans()); n != 0 {
+		t.Errorf("%d spans finished for a skipped request, want 0", n)
+	}
+
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), nopHandler)
+	if n := len(tr.FinishedSpans()); n != 1 {
+		t.Errorf("%d spans finished, want 1", n)
+	}
+}
+
+func TestServeHTTPHooks(t *testing.T) {
+	tracing := &Opentracing{}
+	tr := provisionMock(t, tracing)
+	tracing.opts.spanFilter = func(r *http.Request) bool {
+		return r.Header.Get("X-No-Trace") == ""
+	}
+	var observed []string
+	tracing.opts.spanObserver = func(sp opentracing.Span, r *http.Request) {
+		observed = append(observed, r.URL.Path)
+		sp.SetTag("observed", true)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/filtered", nil)
+	r.Header.Set("X-No-Trace", "1")
+	serve(t, tracing, r, nopHandler)
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/traced", nil), nopHandler)
+
+	if len(observed) != 1 || observed[0] != "/traced" {
+		t.Errorf("observed %v, want [/traced]", observed)
+	}
+	spans := tr.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans finished, want 1", len(spans))
+	}
+	if spans[0].Tag("observed") != true {
+		t.Error("the tag set by the observer is missing")
+	}
+}
+
+func TestServeHTTPDisabled(t *testing.T) {
+	tracing := provision(t, &Opentracing{
+		Config:       Config{Disabled: true},
+		EnvOverrides: envIgnore,
+	})
+	var called bool
+	serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) error {
+		called = true
+		if sp := SpanFromRequest(r); sp != nil {
+			t.Error("request has a span with tracing disabled")
+		}
+		return nil
+	})
+	if !called {
+		t.Error("the next handler was not called")
+	}
+}
+
+func TestServeHTTPResponseHeader(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		restore := SetTestReporter(jaeger.NewInMemoryReporter())
+		tracing := provision(t, &Opentracing{
+			Config:         Config{Sampler: constSampler(sampled)},
+			EnvOverrides:   envIgnore,
+			ResponseHeader: "X-Trace-Id",
+		})
+		restore()
+
+		var sc jaeger.SpanContext
+		placeholders := make(map[string]interface{})
+		w := serve(t, tracing, httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) error {
+			sc = SpanFromRequest(r).Context().(jaeger.SpanContext)
+			repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+			for _, key := range []string{"http.opentracing.trace_id", "http.opentracing.span_id", "http.opentracing.sampled"} {
+				if v, ok := repl.Get(key); ok {
+					placeholders[key] = v
+				}
+			}
+			return nil
+		})
+
+		if got := w.Header().Get("X-Trace-Id"); got != sc.TraceID().String() {
+			t.Errorf("sampled %v: X-Trace-Id = %q, want %q", sampled, got, sc.TraceID())
+		}
+		// the placeholders are left unset for the traces dropped by the sampler
+		want := map[string]interface{}{}
+		if sampled {
+			want = map[string]interface{}{
+				"http.opentracing.trace_id": sc.TraceID().String(),
+				"http.opentracing.span_id":  sc.SpanID().String(),
+				"http.opentracing.sampled":  true,
+			}
+		}
+		if !reflect.DeepEqual(placeholders, want) {
+			t.Errorf("sampled %v: placeholders %v, want %v", sampled, placeholders, want)
+		}
+	}
+}
+
+func benchmarkServeHTTP(b *testing.B, sampled bool) {
+	defer SetTestReporter(jaeger.NewNullReporter())()
+	tracing := provision(b, &Opentracing{
+		Config:       Config{Sampler: constSampler(sampled)},
+		EnvOverrides: envIgnore,
+	})
+	r := httptest.NewRequest(http.MethodGet, "/bench?q=1", nil)
+	ctx := context.WithValue(r.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer())
+	r = r.WithContext(ctx)
+	w := httptest.NewRecorder()
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tracing.ServeHTTP(w, r, next); err != nil {
+			b.Fatal(err)
+		}
+		w.Body.Reset()
+	}
+}
+
+func BenchmarkServeHTTPSampled(b *testing.B) {
+	benchmarkServeHTTP(b, true)
+}
+
+func BenchmarkServeHTTPUnsampled(b *testing.B) {
+	benchmarkServeHTTP(b, false)
+}
@@ -0,0 +1,49 @@
+package tracingtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	_ "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+)
+
+// AssertAdaptGolden adapts caddyfile to JSON and compares the indented
+// result to the golden file. When the UPDATE_GOLDEN environment variable is
+// set, the golden file is rewritten instead.
+func AssertAdaptGolden(t testing.TB, caddyfile, golden string) {
+	t.Helper()
+	adapter := caddyconfig.GetAdapter("caddyfile")
+	if adapter == nil {
+		t.Fatal("no caddyfile adapter")
+	}
+	adapted, warnings, err := adapter.Adapt([]byte(caddyfile), nil)
+	if err != nil {
+		t.Fatalf("adapting: %v", err)
+	}
+	for _, w := range warnings {
+		t.Logf("warning: %s", w)
+	}
+	var got bytes.Buffer
+	if err = json.Indent(&got, adapted, "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	got.WriteByte('\n')
+
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		if err = ioutil.WriteFile(golden, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("adapted config differs from %s:\n%s", golden, got.String())
+	}
+}
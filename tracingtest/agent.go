@@ -0,0 +1,183 @@
+package tracingtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/jaeger-client-go/thrift"
+	"github.com/uber/jaeger-client-go/thrift-gen/agent"
+	jaegerthrift "github.com/uber/jaeger-client-go/thrift-gen/jaeger"
+)
+
+// maxPacketSize is the largest UDP packet sent by the Jaeger clients.
+const maxPacketSize = 65000
+
+// batches keeps the span batches received by the Agent or the Collector.
+type batches struct {
+	mu   sync.Mutex
+	list []*jaegerthrift.Batch
+}
+
+func (b *batches) add(batch *jaegerthrift.Batch) {
+	b.mu.Lock()
+	b.list = append(b.list, batch)
+	b.mu.Unlock()
+}
+
+// Batches returns the batches received so far.
+func (b *batches) Batches() []*jaegerthrift.Batch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*jaegerthrift.Batch(nil), b.list...)
+}
+
+// Spans returns the spans of the batches received so far.
+func (b *batches) Spans() []*jaegerthrift.Span {
+	var spans []*jaegerthrift.Span
+	for _, batch := range b.Batches() {
+		spans = append(spans, batch.Spans...)
+	}
+	return spans
+}
+
+// WaitForSpans waits for n spans to be received and fails the test when
+// fewer are received within DefaultWaitTimeout.
+func (b *batches) WaitForSpans(t testing.TB, n int) []*jaegerthrift.Span {
+	t.Helper()
+	deadline := time.Now().Add(DefaultWaitTimeout)
+	for {
+		spans := b.Spans()
+		if len(spans) >= n {
+			return spans
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d spans, want %d", len(spans), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Agent is a stand-in for the Jaeger agent, decoding the emitBatch calls
+// the clients send over UDP with the compact Thrift protocol. Point
+// reporter.local_agent_host_port at HostPort.
+type Agent struct {
+	batches
+	conn *net.UDPConn
+	done chan struct{}
+}
+
+// StartAgent starts an agent on a local UDP port, closed when the test
+// ends.
+func StartAgent(t testing.TB) *Agent {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	a := &Agent{conn: conn, done: make(chan struct{})}
+	go a.serve(t)
+	t.Cleanup(a.Close)
+	return a
+}
+
+// HostPort returns the address the agent listens on.
+func (a *Agent) HostPort() string {
+	return a.conn.LocalAddr().String()
+}
+
+// Close stops the agent.
+func (a *Agent) Close() {
+	a.conn.Close()
+	<-a.done
+}
+
+func (a *Agent) serve(t testing.TB) {
+	defer close(a.done)
+	packet := make([]byte, maxPacketSize)
+	for {
+		n, err := a.conn.Read(packet)
+		if err != nil {
+			return
+		}
+		batch, err := decodeEmitBatch(packet[:n])
+		if err != nil {
+			t.Errorf("decoding emitBatch: %v", err)
+			continue
+		}
+		a.add(batch)
+	}
+}
+
+// decodeEmitBatch decodes a oneway emitBatch call.
+func decodeEmitBatch(packet []byte) (*jaegerthrift.Batch, error) {
+	ctx := context.Background()
+	buf := thrift.NewTMemoryBufferLen(len(packet))
+	buf.Write(packet)
+	proto := thrift.NewTCompactProtocol(buf)
+	name, _, _, err := proto.ReadMessageBegin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if name != "emitBatch" {
+		return nil, fmt.Errorf("unknown method %s", name)
+	}
+	args := agent.NewAgentEmitBatchArgs()
+	if err = args.Read(ctx, proto); err != nil {
+		return nil, err
+	}
+	if err = proto.ReadMessageEnd(ctx); err != nil {
+		return nil, err
+	}
+	return args.Batch, nil
+}
+
+// Collector is a stand-in for the Jaeger collector, accepting the batches
+// the clients post with the binary Thrift protocol. Point
+// reporter.collector_endpoint at Endpoint.
+type Collector struct {
+	batches
+	*httptest.Server
+}
+
+// StartCollector starts a collector on a local port, closed when the test
+// ends.
+func StartCollector(t testing.TB) *Collector {
+	t.Helper()
+	c := new(Collector)
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// Endpoint returns the URL of the traces endpoint of the collector.
+func (c *Collector) Endpoint() string {
+	return c.URL + "/api/traces"
+}
+
+func (c *Collector) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	buf := thrift.NewTMemoryBufferLen(len(body))
+	buf.Write(body)
+	batch := jaegerthrift.NewBatch()
+	if err = batch.Read(r.Context(), thrift.NewTBinaryProtocolTransport(buf)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.add(batch)
+	w.WriteHeader(http.StatusAccepted)
+}
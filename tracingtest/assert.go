@@ -0,0 +1,80 @@
+package tracingtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+// FindSpan returns the first of spans named operationName, and fails the
+// test when there is none.
+func FindSpan(t testing.TB, spans []*jaeger.Span, operationName string) *jaeger.Span {
+	t.Helper()
+	for _, span := range spans {
+		if span.OperationName() == operationName {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q among %d spans", operationName, len(spans))
+	return nil
+}
+
+// AssertOperation checks the name of span.
+func AssertOperation(t testing.TB, span *jaeger.Span, want string) {
+	t.Helper()
+	if got := span.OperationName(); got != want {
+		t.Errorf("span named %q, want %q", got, want)
+	}
+}
+
+// AssertTag checks the value of the tag key of span. The values are
+// compared by their string form, so that e.g. a uint16 status code
+// matches an int.
+func AssertTag(t testing.TB, span *jaeger.Span, key string, want interface{}) {
+	t.Helper()
+	got, ok := span.Tags()[key]
+	if !ok {
+		t.Errorf("span %q has no tag %s", span.OperationName(), key)
+		return
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("span %q tag %s = %v, want %v", span.OperationName(), key, got, want)
+	}
+}
+
+// AssertNoTag checks that span has no tag key.
+func AssertNoTag(t testing.TB, span *jaeger.Span, key string) {
+	t.Helper()
+	if got, ok := span.Tags()[key]; ok {
+		t.Errorf("span %q has tag %s = %v, want none", span.OperationName(), key, got)
+	}
+}
+
+// AssertParent checks that child is a child of parent.
+func AssertParent(t testing.TB, child, parent *jaeger.Span) {
+	t.Helper()
+	cc, pc := child.SpanContext(), parent.SpanContext()
+	if cc.TraceID() != pc.TraceID() || cc.ParentID() != pc.SpanID() {
+		t.Errorf("span %q (%v) is not a child of span %q (%v)", child.OperationName(), cc, parent.OperationName(), pc)
+	}
+}
+
+// AssertRoot checks that span starts a trace.
+func AssertRoot(t testing.TB, span *jaeger.Span) {
+	t.Helper()
+	if parentID := span.SpanContext().ParentID(); parentID != 0 {
+		t.Errorf("span %q has parent %v, want none", span.OperationName(), parentID)
+	}
+}
+
+// AssertDuration checks that span lasted between min and max. A zero max
+// is no upper bound.
+func AssertDuration(t testing.TB, span *jaeger.Span, min, max time.Duration) {
+	t.Helper()
+	d := span.Duration()
+	if d < min || (max > 0 && d > max) {
+		t.Errorf("span %q lasted %v, want between %v and %v", span.OperationName(), d, min, max)
+	}
+}
@@ -0,0 +1,42 @@
+package tracingtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddytest"
+	"github.com/uber/jaeger-client-go"
+)
+
+// DefaultWaitTimeout is how long WaitForSpans waits for the spans. The
+// server span is finished after the response was written, so it may be
+// reported a little after the client got the response.
+var DefaultWaitTimeout = 2 * time.Second
+
+// Harness runs a Caddyfile with caddytest and records the spans of its
+// opentracing handlers.
+type Harness struct {
+	*caddytest.Tester
+	Recorder *Recorder
+}
+
+// NewHarness installs a recorder and loads caddyfile into the Caddy
+// instance of caddytest. The recorder is uninstalled when the test ends.
+func NewHarness(t *testing.T, caddyfile string) *Harness {
+	rec, restore := Install()
+	t.Cleanup(restore)
+	tester := caddytest.NewTester(t)
+	tester.InitServer(caddyfile, "caddyfile")
+	return &Harness{Tester: tester, Recorder: rec}
+}
+
+// WaitForSpans waits for n spans to be reported and fails the test when
+// fewer are reported within DefaultWaitTimeout.
+func (h *Harness) WaitForSpans(t testing.TB, n int) []*jaeger.Span {
+	t.Helper()
+	spans := h.Recorder.Wait(n, DefaultWaitTimeout)
+	if len(spans) < n {
+		t.Fatalf("got %d spans, want %d", len(spans), n)
+	}
+	return spans
+}
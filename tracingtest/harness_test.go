@@ -0,0 +1,40 @@
+package tracingtest_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/n0trace/caddy-opentracing/tracingtest"
+)
+
+func TestHarness(t *testing.T) {
+	h := tracingtest.NewHarness(t, `
+	{
+		http_port 9080
+		https_port 9443
+	}
+	localhost:9080 {
+		route {
+			opentracing {
+				sampler {
+					type const
+					param 1
+				}
+			}
+			respond /missing 404
+			respond "ok"
+		}
+	}`)
+
+	h.AssertGetResponse("http://localhost:9080/", http.StatusOK, "ok")
+	sp := tracingtest.FindSpan(t, h.WaitForSpans(t, 1), "GET /")
+	tracingtest.AssertRoot(t, sp)
+	tracingtest.AssertTag(t, sp, "http.status_code", http.StatusOK)
+	tracingtest.AssertTag(t, sp, "http.method", http.MethodGet)
+	tracingtest.AssertNoTag(t, sp, "error")
+
+	h.Recorder.Reset()
+	h.AssertGetResponse("http://localhost:9080/missing", http.StatusNotFound, "")
+	sp = tracingtest.FindSpan(t, h.WaitForSpans(t, 1), "GET /missing")
+	tracingtest.AssertTag(t, sp, "http.status_code", http.StatusNotFound)
+}
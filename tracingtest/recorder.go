@@ -0,0 +1,97 @@
+// Package tracingtest records the spans of the opentracing handler in
+// memory, so that the handler and the Caddyfiles using it can be tested
+// without a Jaeger agent:
+//
+//	func TestTrace(t *testing.T) {
+//		h := tracingtest.NewHarness(t, `
+//		{
+//			http_port 9080
+//		}
+//		localhost:9080 {
+//			route {
+//				opentracing {
+//					sampler {
+//						type const
+//						param 1
+//					}
+//				}
+//				respond "ok"
+//			}
+//		}`)
+//		h.AssertGetResponse("http://localhost:9080/", 200, "ok")
+//		sp := tracingtest.FindSpan(t, h.WaitForSpans(t, 1), "GET /")
+//		tracingtest.AssertTag(t, sp, "http.status_code", 200)
+//	}
+//
+// Only the sampled spans are reported, so the tests usually configure a
+// const sampler.
+package tracingtest
+
+import (
+	"sync"
+	"time"
+
+	caddyopentracing "github.com/n0trace/caddy-opentracing"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Recorder is a jaeger.Reporter keeping the reported spans in memory.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*jaeger.Span
+}
+
+// NewRecorder returns an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Install makes the handlers provisioned from now on report to a new
+// recorder, until restore is called.
+func Install() (rec *Recorder, restore func()) {
+	rec = NewRecorder()
+	return rec, caddyopentracing.SetTestReporter(rec)
+}
+
+// Report implements jaeger.Reporter.
+func (rec *Recorder) Report(span *jaeger.Span) {
+	span.Retain()
+	rec.mu.Lock()
+	rec.spans = append(rec.spans, span)
+	rec.mu.Unlock()
+}
+
+// Close implements jaeger.Reporter. The recorded spans are kept, since the
+// recorder outlives the tracers closed by a config reload.
+func (rec *Recorder) Close() {}
+
+// Spans returns the spans reported so far, in the order they finished.
+func (rec *Recorder) Spans() []*jaeger.Span {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]*jaeger.Span(nil), rec.spans...)
+}
+
+// Reset forgets the spans reported so far.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	spans := rec.spans
+	rec.spans = nil
+	rec.mu.Unlock()
+	for _, span := range spans {
+		span.Release()
+	}
+}
+
+// Wait waits up to timeout for at least n spans to be reported, and
+// returns the spans reported so far.
+func (rec *Recorder) Wait(n int, timeout time.Duration) []*jaeger.Span {
+	deadline := time.Now().Add(timeout)
+	for {
+		spans := rec.Spans()
+		if len(spans) >= n || time.Now().After(deadline) {
+			return spans
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
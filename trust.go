@@ -0,0 +1,142 @@
+package opentracing
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// What to do with the trace context of untrusted requests.
+const (
+	untrustedRestart = "restart"
+	untrustedDrop    = "drop"
+	untrustedHonor   = "honor"
+)
+
+const (
+	linkTraceIDKey = "link.trace_id"
+	linkSpanIDKey  = "link.span_id"
+)
+
+// TrustConfig decides whose incoming trace context is honored. A request is
+// trusted when it comes from one of the sources or matches one of the
+// matcher sets. With neither of them, no request is trusted.
+type TrustConfig struct {
+	// Sources lists the IP addresses or CIDR ranges of the trusted peers.
+	Sources []string `json:"sources"`
+
+	// MatchersRaw trusts the requests matching one of the matcher sets.
+	MatchersRaw caddyhttp.RawMatcherSets `json:"match" caddy:"namespace=http.matchers"`
+
+	// Untrusted is what to do with the trace context of untrusted requests:
+	// - "restart" starts a new trace, tagged with the IDs of the incoming one (default)
+	// - "drop" starts a new trace, discarding the incoming one
+	// - "honor" continues the incoming trace
+	Untrusted string `json:"untrusted"`
+
+	// StripHeaders removes the tracing headers of untrusted requests even
+	// when their context is honored. They are always removed otherwise, so
+	// that the upstreams do not continue a trace which was not honored.
+	StripHeaders bool `json:"strip_headers"`
+
+	sources  []*net.IPNet
+	matchers caddyhttp.MatcherSets
+}
+
+// provision parses the sources and loads the matchers.
+func (tc *TrustConfig) provision(ctx caddy.Context) error {
+	switch tc.Untrusted {
+	case "":
+		tc.Untrusted = untrustedRestart
+	case untrustedRestart, untrustedDrop, untrustedHonor:
+	default:
+		return fmt.Errorf("unknown mode for untrusted context (%s)", tc.Untrusted)
+	}
+
+	for _, source := range tc.Sources {
+		ipNet, err := parseCIDR(source)
+		if err != nil {
+			return err
+		}
+		tc.sources = append(tc.sources, ipNet)
+	}
+
+	mods, err := ctx.LoadModule(tc, "MatchersRaw")
+	if err != nil {
+		return fmt.Errorf("loading matchers: %v", err)
+	}
+	return tc.matchers.FromInterface(mods)
+}
+
+// trusted reports whether the trace context of r can be honored.
+func (tc *TrustConfig) trusted(r *http.Request) bool {
+	if ip := remoteIP(r); ip != nil {
+		for _, ipNet := range tc.sources {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return len(tc.matchers) > 0 && tc.matchers.AnyMatch(r)
+}
+
+// stripContextHeaders removes the headers carrying a trace context from r.
+func (tracing *Opentracing) stripContextHeaders(r *http.Request) {
+	r.Header.Del(tracing.headers.TraceContextHeaderName)
+	r.Header.Del(tracing.headers.JaegerDebugHeader)
+	r.Header.Del(tracing.headers.JaegerBaggageHeader)
+	for name := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), tracing.headers.TraceBaggageHeaderPrefix) {
+			delete(r.Header, name)
+		}
+	}
+}
+
+// hasContextHeaders reports whether r carries any of the headers jaeger
+// reads a trace context or baggage from, so that extracting can be skipped
+// for the requests which do not.
+func (tracing *Opentracing) hasContextHeaders(r *http.Request) bool {
+	for name := range r.Header {
+		if strings.EqualFold(name, tracing.headers.TraceContextHeaderName) ||
+			strings.EqualFold(name, tracing.headers.JaegerDebugHeader) ||
+			strings.EqualFold(name, tracing.headers.JaegerBaggageHeader) ||
+			len(name) >= len(tracing.headers.TraceBaggageHeaderPrefix) &&
+				strings.EqualFold(name[:len(tracing.headers.TraceBaggageHeaderPrefix)], tracing.headers.TraceBaggageHeaderPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDR parses a CIDR range, or a single IP address.
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %s", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR range: %v", err)
+	}
+	return ipNet, nil
+}
+
+// remoteIP returns the IP address of the peer of r.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,75 @@
+package opentracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustContextHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		remoteAddr   string
+		untrusted    string
+		stripHeaders bool
+		wantHeaders  bool
+	}{
+		{name: "trusted", remoteAddr: "10.1.2.3:1234", untrusted: untrustedRestart, wantHeaders: true},
+		{name: "trusted strip", remoteAddr: "10.1.2.3:1234", untrusted: untrustedRestart, stripHeaders: true, wantHeaders: true},
+		{name: "restart", remoteAddr: "203.0.113.1:1234", untrusted: untrustedRestart, wantHeaders: false},
+		{name: "drop", remoteAddr: "203.0.113.1:1234", untrusted: untrustedDrop, wantHeaders: false},
+		{name: "honor", remoteAddr: "203.0.113.1:1234", untrusted: untrustedHonor, wantHeaders: true},
+		{name: "honor strip", remoteAddr: "203.0.113.1:1234", untrusted: untrustedHonor, stripHeaders: true, wantHeaders: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracing := provision(t, &Opentracing{
+				Config:       Config{Sampler: constSampler(true)},
+				EnvOverrides: envIgnore,
+				TrustContext: &TrustConfig{
+					Sources:      []string{"10.0.0.0/8"},
+					Untrusted:    tc.untrusted,
+					StripHeaders: tc.stripHeaders,
+				},
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			r.Header.Set("Uber-Trace-Id", "1f:2f:0:1")
+			r.Header.Set("Uberctx-Tenant", "acme")
+			r.Header.Set("Jaeger-Baggage", "user=alice")
+
+			var header http.Header
+			serve(t, tracing, r, func(w http.ResponseWriter, r *http.Request) error {
+				header = r.Header.Clone()
+				return nil
+			})
+			for _, name := range []string{"Uber-Trace-Id", "Uberctx-Tenant", "Jaeger-Baggage"} {
+				if got := header.Get(name) != ""; got != tc.wantHeaders {
+					t.Errorf("%s passed on = %v, want %v", name, got, tc.wantHeaders)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{in: "192.168.1.1", want: "192.168.1.1/32"},
+		{in: "::1", want: "::1/128"},
+	} {
+		ipNet, err := parseCIDR(tc.in)
+		if err != nil {
+			t.Errorf("parseCIDR(%s): %v", tc.in, err)
+			continue
+		}
+		if got := ipNet.String(); got != tc.want {
+			t.Errorf("parseCIDR(%s) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+	if _, err := parseCIDR("not an ip"); err == nil {
+		t.Error("parseCIDR accepted an invalid address")
+	}
+}
@@ -0,0 +1,171 @@
+package opentracing
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+const (
+	upstreamOperationName = "upstream"
+	upstreamDialKey       = "upstream.dial"
+	upstreamRetryKey      = "upstream.retry"
+	upstreamStatusKey     = "upstream.status_code"
+	upstreamRelayedKey    = "upstream.relayed_status_code"
+	upstreamPolicyKey     = "upstream.lb_policy"
+	upstreamLatencyKey    = "upstream.latency_ms"
+)
+
+// upstreamTracer records a client span for every upstream attempt made with
+// the request context, e.g. by reverse_proxy when it retries or load balances
+// across several upstreams. The hooks of httptrace.ClientTrace may be called
+// from the dialing goroutines of the transport, so all state is guarded by mu.
+type upstreamTracer struct {
+	tr     opentracing.Tracer
+	parent opentracing.SpanContext
+	repl   *caddy.Replacer
+
+	mu        sync.Mutex
+	span      opentracing.Span
+	start     time.Time
+	attempts  int
+	responded bool
+}
+
+func newUpstreamTracer(tr opentracing.Tracer, parent opentracing.Span, r *http.Request) *upstreamTracer {
+	repl, _ := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	return &upstreamTracer{
+		tr:     tr,
+		parent: parent.Context(),
+		repl:   repl,
+	}
+}
+
+// clientTrace returns the hooks to attach to the request context.
+func (ut *upstreamTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: ut.getConn,
+		GotConn: func(info httptrace.GotConnInfo) {
+			ut.log(log.String("event", "got_conn"), log.Bool("reused", info.Reused), log.Bool("was_idle", info.WasIdle))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			ut.log(log.String("event", "dns_start"), log.String("host", info.Host))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			ut.logError("dns_done", info.Err)
+		},
+		ConnectStart: func(network, addr string) {
+			ut.log(log.String("event", "connect_start"), log.String("network", network), log.String("addr", addr))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			ut.logError("connect_done", err, log.String("network", network), log.String("addr", addr))
+		},
+		TLSHandshakeStart: func() {
+			ut.log(log.String("event", "tls_handshake_start"))
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			ut.logError("tls_handshake_done", err, log.String("negotiated_protocol", state.NegotiatedProtocol))
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			ut.logError("wrote_request", info.Err)
+		},
+		GotFirstResponseByte: ut.gotFirstResponseByte,
+	}
+}
+
+// getConn starts the span of a new attempt. An attempt that is still open at
+// this point never completed, so it is finished as failed.
+func (ut *upstreamTracer) getConn(hostPort string) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	ut.finishLocked(0)
+
+	sp := ut.tr.StartSpan(upstreamOperationName, opentracing.ChildOf(ut.parent), ext.SpanKindRPCClient)
+	ext.PeerAddress.Set(sp, hostPort)
+	if ut.repl != nil {
+		if dial, ok := ut.repl.GetString("http.reverse_proxy.upstream.address"); ok && dial != "" {
+			sp.SetTag(upstreamDialKey, dial)
+		}
+		if policy, ok := ut.repl.GetString(lbPolicyPlaceholder); ok && policy != "" {
+			sp.SetTag(upstreamPolicyKey, policy)
+		}
+	}
+	sp.SetTag(upstreamRetryKey, ut.attempts)
+
+	ut.span = sp
+	ut.start = time.Now()
+	ut.attempts++
+	ut.responded = false
+}
+
+func (ut *upstreamTracer) gotFirstResponseByte() {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if ut.span == nil {
+		return
+	}
+	ut.responded = true
+	ut.span.SetTag(upstreamLatencyKey, float64(time.Since(ut.start))/float64(time.Millisecond))
+	ut.span.LogFields(log.String("event", "first_response_byte"))
+}
+
+func (ut *upstreamTracer) log(fields ...log.Field) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if ut.span != nil {
+		ut.span.LogFields(fields...)
+	}
+}
+
+func (ut *upstreamTracer) logError(event string, err error, fields ...log.Field) {
+	fields = append([]log.Field{log.String("event", event)}, fields...)
+	if err != nil {
+		fields = append(fields, log.Error(err))
+	}
+	ut.log(fields...)
+}
+
+// finish closes the attempt that is still open once the handler chain
+// returned, given the status written to the client.
+func (ut *upstreamTracer) finish(status int) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	ut.finishLocked(status)
+}
+
+// finishLocked closes the open attempt. The status of the upstream response
+// is only known when reverse_proxy sets {http.reverse_proxy.status_code},
+// i.e. with handle_response. Otherwise the attempt which got a response is
+// tagged with the status relayed to the client, which a handler may have
+// changed.
+func (ut *upstreamTracer) finishLocked(relayed int) {
+	if ut.span == nil {
+		return
+	}
+	status := 0
+	if ut.responded && ut.repl != nil {
+		if v, ok := ut.repl.Get("http.reverse_proxy.status_code"); ok {
+			status, _ = v.(int)
+		}
+	}
+	switch {
+	case status > 0:
+		ut.span.SetTag(upstreamStatusKey, status)
+	case ut.responded && relayed > 0:
+		ut.span.SetTag(upstreamRelayedKey, relayed)
+		status = relayed
+	}
+	if !ut.responded || status >= http.StatusInternalServerError {
+		ext.Error.Set(ut.span, true)
+	}
+	ut.span.Finish()
+	ut.span = nil
+}
@@ -0,0 +1,91 @@
+package opentracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func newUpstreamRequest() (*http.Request, *caddy.Replacer) {
+	repl := caddy.NewReplacer()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	return r.WithContext(context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl)), repl
+}
+
+func TestUpstreamTracerAttempts(t *testing.T) {
+	tr := mocktracer.New()
+	parent := tr.StartSpan("server")
+	r, repl := newUpstreamRequest()
+	ut := newUpstreamTracer(tr, parent, r)
+
+	repl.Set(lbPolicyPlaceholder, "round_robin")
+	repl.Set("http.reverse_proxy.upstream.address", "a:80")
+	ut.getConn("a:80")
+	repl.Set("http.reverse_proxy.upstream.address", "b:80")
+	ut.getConn("b:80")
+	ut.gotFirstResponseByte()
+	ut.finish(http.StatusBadGateway)
+
+	spans := tr.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	first, second := spans[0], spans[1]
+	if first.Tag(upstreamDialKey) != "a:80" || first.Tag(upstreamRetryKey) != 0 || first.Tag("error") != true {
+		t.Errorf("first attempt tags = %v", first.Tags())
+	}
+	if second.Tag(upstreamDialKey) != "b:80" || second.Tag(upstreamRetryKey) != 1 {
+		t.Errorf("second attempt tags = %v", second.Tags())
+	}
+	if second.Tag(upstreamPolicyKey) != "round_robin" {
+		t.Errorf("lb policy = %v, want round_robin", second.Tag(upstreamPolicyKey))
+	}
+	if second.Tag(upstreamRelayedKey) != http.StatusBadGateway || second.Tag(upstreamStatusKey) != nil {
+		t.Errorf("status tags = %v", second.Tags())
+	}
+	if second.ParentID != parent.Context().(mocktracer.MockSpanContext).SpanID {
+		t.Errorf("attempt is not a child of the server span")
+	}
+}
+
+func TestUpstreamTracerResponseStatus(t *testing.T) {
+	tr := mocktracer.New()
+	r, repl := newUpstreamRequest()
+	ut := newUpstreamTracer(tr, tr.StartSpan("server"), r)
+
+	ut.getConn("a:80")
+	ut.gotFirstResponseByte()
+	repl.Set("http.reverse_proxy.status_code", http.StatusNotFound)
+	ut.finish(http.StatusOK)
+
+	sp := tr.FinishedSpans()[0]
+	if sp.Tag(upstreamStatusKey) != http.StatusNotFound || sp.Tag(upstreamRelayedKey) != nil {
+		t.Errorf("status tags = %v", sp.Tags())
+	}
+	if sp.Tag("error") != nil {
+		t.Errorf("attempt flagged as error")
+	}
+}
+
+type firstSelection struct{}
+
+func (firstSelection) Select(pool reverseproxy.UpstreamPool, _ *http.Request, _ http.ResponseWriter) *reverseproxy.Upstream {
+	return pool[0]
+}
+
+func TestTracedSelection(t *testing.T) {
+	s := &TracedSelection{selection: firstSelection{}, name: "first"}
+	r, repl := newUpstreamRequest()
+	pool := reverseproxy.UpstreamPool{{Dial: "a:80"}}
+	if up := s.Select(pool, r, httptest.NewRecorder()); up != pool[0] {
+		t.Errorf("selected %v, want %v", up, pool[0])
+	}
+	if policy, _ := repl.GetString(lbPolicyPlaceholder); policy != "first" {
+		t.Errorf("policy placeholder = %q, want first", policy)
+	}
+}